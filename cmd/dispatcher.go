@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	classifier "github.com/barasher/FileDateDispatcher/internal"
 
@@ -16,9 +17,16 @@ const (
 	retConfFailure int = 1
 	retExecFailure int = 2
 
-	defaultLoggingLevel     string = "info"
-	defaultBatchSize        uint   = uint(10)
-	defaultOutputDateFormat string = "2006_01"
+	planFormatText string = "text"
+	planFormatJSON string = "json"
+
+	defaultLoggingLevel      string = "info"
+	defaultBatchSize         uint   = uint(10)
+	defaultWorkers           uint   = uint(1)
+	defaultOutputDateFormat  string = "2006_01"
+	defaultMetadataExtractor string = "exiftool"
+	defaultCollisionPolicy   string = classifier.CollisionRename
+	defaultOperation         string = classifier.OperationMove
 )
 
 var loggingLevels = map[string]logrus.Level{
@@ -30,16 +38,184 @@ var loggingLevels = map[string]logrus.Level{
 	"panic": logrus.PanicLevel,
 }
 
+var metadataExtractors = map[string]classifier.ExtractorFactory{
+	"exiftool": classifier.NewExiftoolExtractor,
+}
+
 type dateField struct {
-	Field   string `json:"field"`
-	Pattern string `json:"pattern"`
+	Source      string   `json:"source"`
+	Field       string   `json:"field"`
+	Pattern     string   `json:"pattern"`
+	Patterns    []string `json:"patterns"`
+	Regexps     []string `json:"regexps"`
+	Timestamp   string   `json:"timestamp"`
+	Timezone    string   `json:"timezone"`
+	OffsetField string   `json:"offsetField"`
+	SubSecField string   `json:"subSecField"`
+	SkipZero    bool     `json:"skipZero"`
 }
 
-type dispatcherConf struct {
-	LoggingLevel     string      `json:"loggingLevel"`
-	BatchSize        uint        `json:"batchSize"`
+// patterns returns the patterns of the field, the single pattern coming first
+func (df dateField) patterns() []string {
+	if df.Pattern == "" {
+		return df.Patterns
+	}
+	return append([]string{df.Pattern}, df.Patterns...)
+}
+
+func (df dateField) dateField() classifier.DateField {
+	return classifier.DateField{Source: df.Source, Field: df.Field, Patterns: df.patterns(), Regexps: df.Regexps, Timestamp: df.Timestamp, Timezone: df.Timezone, OffsetField: df.OffsetField, SubSecField: df.SubSecField, SkipZero: df.SkipZero}
+}
+
+func dateFields(dfs []dateField) []classifier.DateField {
+	res := make([]classifier.DateField, len(dfs))
+	for i, v := range dfs {
+		res[i] = v.dateField()
+	}
+	return res
+}
+
+// checkDateFields checks that a pattern is specified for the metadata date fields
+func checkDateFields(dfs []dateField) error {
+	for _, df := range dfs {
+		if (df.Source == "" || df.Source == classifier.SourceMetadata) && len(df.patterns()) == 0 {
+			return fmt.Errorf("No pattern specified for date field %v", df.Field)
+		}
+	}
+	return nil
+}
+
+// companionRule groups companion files with their primary file
+type companionRule struct {
+	Primaries  []string `json:"primaries"`
+	Companions []string `json:"companions"`
+}
+
+// defaultCompanions designates the built-in companion rules in the configuration
+const defaultCompanions = "default"
+
+// companionRules are the companion rules, specified as a list or as "default" for the built-in rules
+type companionRules []companionRule
+
+func (crs *companionRules) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		if name != defaultCompanions {
+			return fmt.Errorf("unknown companion rules %v", name)
+		}
+		*crs = make(companionRules, len(classifier.DefaultCompanionRules))
+		for i, r := range classifier.DefaultCompanionRules {
+			(*crs)[i] = companionRule{Primaries: r.Primaries, Companions: r.Companions}
+		}
+		return nil
+	}
+	var rules []companionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	*crs = rules
+	return nil
+}
+
+// clockCorrection shifts the dates of the files shot by a camera whose clock was wrong. From and To
+// are RFC3339 dates, Shift a golang duration (https://golang.org/pkg/time/#ParseDuration).
+type clockCorrection struct {
+	Name   string            `json:"name"`
+	Match  map[string]string `json:"match"`
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Years  int               `json:"years"`
+	Months int               `json:"months"`
+	Days   int               `json:"days"`
+	Shift  string            `json:"shift"`
+}
+
+func (cc clockCorrection) correction() (classifier.ClockCorrection, error) {
+	c := classifier.ClockCorrection{Name: cc.Name, Match: cc.Match, Years: cc.Years, Months: cc.Months, Days: cc.Days}
+	var err error
+	if cc.From != "" {
+		if c.From, err = time.Parse(time.RFC3339, cc.From); err != nil {
+			return c, fmt.Errorf("invalid from date in clock correction: %v", err)
+		}
+	}
+	if cc.To != "" {
+		if c.To, err = time.Parse(time.RFC3339, cc.To); err != nil {
+			return c, fmt.Errorf("invalid to date in clock correction: %v", err)
+		}
+	}
+	if cc.Shift != "" {
+		if c.Shift, err = time.ParseDuration(cc.Shift); err != nil {
+			return c, fmt.Errorf("invalid shift in clock correction: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// rule dispatches the files it matches with its own settings, the empty settings being inherited
+type rule struct {
+	Name             string      `json:"name"`
+	Match            ruleMatch   `json:"match"`
 	DateFields       []dateField `json:"dateFields"`
 	OutputDateFormat string      `json:"outputDateFormat"`
+	Destination      string      `json:"destination"`
+	Rename           string      `json:"rename"`
+	Operation        string      `json:"operation"`
+}
+
+// ruleMatch describes the files a rule applies to, sizes being expressed in bytes
+type ruleMatch struct {
+	Extensions []string          `json:"extensions"`
+	MimeTypes  []string          `json:"mimeTypes"`
+	Tags       map[string]string `json:"tags"`
+	MinSize    int64             `json:"minSize"`
+	MaxSize    int64             `json:"maxSize"`
+	Paths      []string          `json:"paths"`
+}
+
+func (r rule) rule() classifier.Rule {
+	m := r.Match
+	return classifier.Rule{
+		Name:             r.Name,
+		Match:            classifier.RuleMatch{Extensions: m.Extensions, MimeTypes: m.MimeTypes, Tags: m.Tags, MinSize: m.MinSize, MaxSize: m.MaxSize, Paths: m.Paths},
+		DateFields:       dateFields(r.DateFields),
+		OutputDateFormat: r.OutputDateFormat,
+		Destination:      r.Destination,
+		Rename:           r.Rename,
+		Operation:        r.Operation,
+	}
+}
+
+// preserveConf lists the attributes to preserve when files are copied, every attribute is preserved by default
+type preserveConf struct {
+	Times     *bool `json:"times"`
+	Mode      *bool `json:"mode"`
+	Ownership *bool `json:"ownership"`
+	Xattrs    *bool `json:"xattrs"`
+}
+
+type dispatcherConf struct {
+	LoggingLevel      string            `json:"loggingLevel"`
+	BatchSize         uint              `json:"batchSize"`
+	Workers           uint              `json:"workers"`
+	DateFields        []dateField       `json:"dateFields"`
+	OutputDateFormat  string            `json:"outputDateFormat"`
+	OutputTimezone    string            `json:"outputTimezone"`
+	Destination       string            `json:"destination"`
+	Rename            string            `json:"rename"`
+	Locale            string            `json:"locale"`
+	FiscalYearStart   int               `json:"fiscalYearStart"`
+	MetadataExtractor string            `json:"metadataExtractor"`
+	CollisionPolicy   string            `json:"collisionPolicy"`
+	Operation         string            `json:"operation"`
+	Preserve          preserveConf      `json:"preserve"`
+	UnknownDateFolder string            `json:"unknownDateFolder"`
+	ErrorFolder       string            `json:"errorFolder"`
+	Companions        companionRules    `json:"companions"`
+	ClockCorrections  []clockCorrection `json:"clockCorrections"`
+	Rules             []rule            `json:"rules"`
+
+	// corrections are the parsed clock corrections
+	corrections []classifier.ClockCorrection
 }
 
 func main() {
@@ -51,6 +227,10 @@ func doMain(args []string) int {
 	from := cmd.String("s", "", "Source folder")
 	to := cmd.String("d", "", "Destination folder")
 	confFile := cmd.String("c", "", "Configuration file")
+	var dryRun bool
+	cmd.BoolVar(&dryRun, "n", false, "Dry run : prints the planned moves without touching any file")
+	cmd.BoolVar(&dryRun, "dry-run", false, "Dry run : prints the planned moves without touching any file")
+	planFormat := cmd.String("f", planFormatText, "Dry run output format (text, json)")
 
 	err := cmd.Parse(args[1:])
 	if err != nil {
@@ -60,6 +240,11 @@ func doMain(args []string) int {
 		return retConfFailure
 	}
 
+	if *planFormat != planFormatText && *planFormat != planFormatJSON {
+		logrus.Errorf("Unknown dry run output format (%v)", *planFormat)
+		return retConfFailure
+	}
+
 	if *confFile == "" {
 		logrus.Errorf("No configuration file provided (-c)")
 		return retConfFailure
@@ -79,12 +264,36 @@ func doMain(args []string) int {
 
 	var classifierOpts []func(*classifier.Classifier) error
 	classifierOpts = append(classifierOpts, classifier.OptBatchSize(conf.BatchSize))
-	dfs := map[string]string{}
-	for _, v := range conf.DateFields {
-		dfs[v.Field] = v.Pattern
-	}
-	classifierOpts = append(classifierOpts, classifier.OptDateFields(dfs))
+	classifierOpts = append(classifierOpts, classifier.OptWorkers(conf.Workers))
+	classifierOpts = append(classifierOpts, classifier.OptDateFields(dateFields(conf.DateFields)))
 	classifierOpts = append(classifierOpts, classifier.OptOutputDateFormat(conf.OutputDateFormat))
+	classifierOpts = append(classifierOpts, classifier.OptOutputTimezone(conf.OutputTimezone))
+	classifierOpts = append(classifierOpts, classifier.OptDestination(conf.Destination))
+	classifierOpts = append(classifierOpts, classifier.OptRename(conf.Rename))
+	classifierOpts = append(classifierOpts, classifier.OptLocale(conf.Locale))
+	classifierOpts = append(classifierOpts, classifier.OptFiscalYearStart(time.Month(conf.FiscalYearStart)))
+	extractorFactory, found := metadataExtractors[conf.MetadataExtractor]
+	if !found {
+		logrus.Errorf("Unknown metadata extractor specified (%v)", conf.MetadataExtractor)
+		return retConfFailure
+	}
+	classifierOpts = append(classifierOpts, classifier.OptMetadataExtractor(extractorFactory))
+	classifierOpts = append(classifierOpts, classifier.OptCollisionPolicy(conf.CollisionPolicy))
+	classifierOpts = append(classifierOpts, classifier.OptOperation(conf.Operation))
+	classifierOpts = append(classifierOpts, classifier.OptPreserve(conf.Preserve.preservation()))
+	classifierOpts = append(classifierOpts, classifier.OptUnknownDateFolder(conf.UnknownDateFolder))
+	classifierOpts = append(classifierOpts, classifier.OptErrorFolder(conf.ErrorFolder))
+	crs := make([]classifier.CompanionRule, len(conf.Companions))
+	for i, v := range conf.Companions {
+		crs[i] = classifier.CompanionRule{Primaries: v.Primaries, Companions: v.Companions}
+	}
+	classifierOpts = append(classifierOpts, classifier.OptCompanionRules(crs))
+	classifierOpts = append(classifierOpts, classifier.OptClockCorrections(conf.corrections))
+	rules := make([]classifier.Rule, len(conf.Rules))
+	for i, v := range conf.Rules {
+		rules[i] = v.rule()
+	}
+	classifierOpts = append(classifierOpts, classifier.OptRules(rules))
 
 	if *from == "" {
 		logrus.Errorf("No source provided (-s)")
@@ -102,6 +311,10 @@ func doMain(args []string) int {
 		return retExecFailure
 	}
 
+	if dryRun {
+		return printPlan(c, *from, *to, *planFormat)
+	}
+
 	if err := c.Classify(*from, *to); err != nil {
 		logrus.Errorf("Error while classifying: %v", err)
 		return retExecFailure
@@ -110,6 +323,35 @@ func doMain(args []string) int {
 	return retOk
 }
 
+func printPlan(c *classifier.Classifier, from string, to string, format string) int {
+	p, err := c.Plan(from, to)
+	if err != nil {
+		logrus.Errorf("Error while planning: %v", err)
+		return retExecFailure
+	}
+	write := p.WriteText
+	if format == planFormatJSON {
+		write = p.WriteJSON
+	}
+	if err := write(os.Stdout); err != nil {
+		logrus.Errorf("Error while printing plan: %v", err)
+		return retExecFailure
+	}
+	return retOk
+}
+
+func (p preserveConf) preservation() classifier.Preservation {
+	enabled := func(b *bool) bool {
+		return b == nil || *b
+	}
+	return classifier.Preservation{
+		Times:     enabled(p.Times),
+		Mode:      enabled(p.Mode),
+		Ownership: enabled(p.Ownership),
+		Xattrs:    enabled(p.Xattrs),
+	}
+}
+
 func loadConf(confFile string) (dispatcherConf, error) {
 	c := dispatcherConf{}
 
@@ -126,6 +368,10 @@ func loadConf(confFile string) (dispatcherConf, error) {
 		c.BatchSize = defaultBatchSize
 		logrus.Warnf("No batch size specified (or 0), using default (%v)", c.BatchSize)
 	}
+	if c.Workers < 1 {
+		c.Workers = defaultWorkers
+		logrus.Warnf("No workers count specified (or 0), using default (%v)", c.Workers)
+	}
 	if c.LoggingLevel == "" {
 		c.LoggingLevel = defaultLoggingLevel
 		logrus.Warnf("No logging level specified, using default (%v)", c.LoggingLevel)
@@ -134,10 +380,39 @@ func loadConf(confFile string) (dispatcherConf, error) {
 		c.OutputDateFormat = defaultOutputDateFormat
 		logrus.Warnf("No output date format specified, using default (%v)", c.OutputDateFormat)
 	}
+	if c.MetadataExtractor == "" {
+		c.MetadataExtractor = defaultMetadataExtractor
+		logrus.Warnf("No metadata extractor specified, using default (%v)", c.MetadataExtractor)
+	}
+	if c.CollisionPolicy == "" {
+		c.CollisionPolicy = defaultCollisionPolicy
+		logrus.Warnf("No collision policy specified, using default (%v)", c.CollisionPolicy)
+	}
+	if c.Operation == "" {
+		c.Operation = defaultOperation
+		logrus.Warnf("No operation specified, using default (%v)", c.Operation)
+	}
 
 	if len(c.DateFields) == 0 {
 		return c, fmt.Errorf("No date fields specified in the configuration file")
 	}
+	if c.FiscalYearStart < 0 || c.FiscalYearStart > 12 {
+		return c, fmt.Errorf("Invalid fiscal year start month (%v)", c.FiscalYearStart)
+	}
+	if err := checkDateFields(c.DateFields); err != nil {
+		return c, err
+	}
+	for _, r := range c.Rules {
+		if err := checkDateFields(r.DateFields); err != nil {
+			return c, fmt.Errorf("Invalid rule %v: %v", r.Name, err)
+		}
+	}
+	c.corrections = make([]classifier.ClockCorrection, len(c.ClockCorrections))
+	for i, cc := range c.ClockCorrections {
+		if c.corrections[i], err = cc.correction(); err != nil {
+			return c, err
+		}
+	}
 
 	return c, nil
 }
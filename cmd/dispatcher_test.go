@@ -1,15 +1,26 @@
 package main
 
 import (
+	"os/exec"
 	"testing"
+	"time"
+
+	classifier "github.com/barasher/FileDateDispatcher/internal"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestLoadConf(t *testing.T) {
 	expDateFields := []dateField{
-		{"CreateDate", "2006:01:02 15:04:05"},
-		{"Media Create Date", "2006:01:02 15:04:05"},
+		{Field: "CreateDate", Pattern: "2006:01:02 15:04:05"},
+		{Field: "Media Create Date", Pattern: "2006:01:02 15:04:05"},
+	}
+	expNominalDateFields := []dateField{
+		{Field: "CreateDate", Pattern: "2006:01:02 15:04:05", SkipZero: true},
+		{Field: "Media Create Date", Patterns: []string{"exif-tz", "exif"}, Timezone: "utc"},
+		{Field: "DateTimeOriginal", Pattern: "exif", Timezone: "Europe/Paris", OffsetField: "OffsetTimeOriginal", SubSecField: "SubSecTimeOriginal"},
+		{Source: "filename", Regexps: []string{`(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`}},
+		{Source: "filesystem", Timestamp: "birth"},
 	}
 	var tcs = []struct {
 		tcID                string
@@ -17,14 +28,25 @@ func TestLoadConf(t *testing.T) {
 		expError            bool
 		expLoggingLevel     string
 		expBatchSize        uint
+		expWorkers          uint
 		expDateFields       []dateField
 		expOutputDateFormat string
+		expExtractor        string
+		expCollisionPolicy  string
+		expOperation        string
+		expPreserve         classifier.Preservation
+		expUnknownFolder    string
+		expErrorFolder      string
 	}{
-		{"nominal", "../testdata/conf/nominal.json", false, "warning", 42, expDateFields, "2016+01"},
-		{"default", "../testdata/conf/default.json", false, defaultLoggingLevel, defaultBatchSize, expDateFields, defaultOutputDateFormat},
-		{"unparsable", "../testdata/conf/unparsable.json", true, "", 0, nil, ""},
-		{"nonExisting", "../testdata/conf/nonExisting.json", true, "", 0, nil, ""},
-		{"noDateField", "../testdata/conf/noDateField.json", true, "", 0, nil, ""},
+		{"nominal", "../testdata/conf/nominal.json", false, "warning", 42, 4, expNominalDateFields, "2016+01", "exiftool", "skip", "copy", classifier.Preservation{Mode: true, Ownership: true}, "unknown", "/tmp/errors"},
+		{"default", "../testdata/conf/default.json", false, defaultLoggingLevel, defaultBatchSize, defaultWorkers, expDateFields, defaultOutputDateFormat, defaultMetadataExtractor, defaultCollisionPolicy, defaultOperation, classifier.PreserveAll, "", ""},
+		{"unparsable", "../testdata/conf/unparsable.json", true, "", 0, 0, nil, "", "", "", "", classifier.Preservation{}, "", ""},
+		{"nonExisting", "../testdata/conf/nonExisting.json", true, "", 0, 0, nil, "", "", "", "", classifier.Preservation{}, "", ""},
+		{"noDateField", "../testdata/conf/noDateField.json", true, "", 0, 0, nil, "", "", "", "", classifier.Preservation{}, "", ""},
+		{"noPattern", "../testdata/conf/noPattern.json", true, "", 0, 0, nil, "", "", "", "", classifier.Preservation{}, "", ""},
+		{"invalidCorrection", "../testdata/conf/invalidCorrection.json", true, "", 0, 0, nil, "", "", "", "", classifier.Preservation{}, "", ""},
+		{"invalidRule", "../testdata/conf/invalidRule.json", true, "", 0, 0, nil, "", "", "", "", classifier.Preservation{}, "", ""},
+		{"invalidFiscalYearStart", "../testdata/conf/invalidFiscalYearStart.json", true, "", 0, 0, nil, "", "", "", "", classifier.Preservation{}, "", ""},
 	}
 
 	for _, tc := range tcs {
@@ -34,12 +56,160 @@ func TestLoadConf(t *testing.T) {
 			if !tc.expError {
 				assert.Equal(t, tc.expLoggingLevel, c.LoggingLevel)
 				assert.Equal(t, tc.expBatchSize, c.BatchSize)
+				assert.Equal(t, tc.expWorkers, c.Workers)
 				assert.Equal(t, tc.expDateFields, c.DateFields)
+				assert.Equal(t, tc.expExtractor, c.MetadataExtractor)
+				assert.Equal(t, tc.expCollisionPolicy, c.CollisionPolicy)
+				assert.Equal(t, tc.expOperation, c.Operation)
+				assert.Equal(t, tc.expPreserve, c.Preserve.preservation())
+				assert.Equal(t, tc.expUnknownFolder, c.UnknownDateFolder)
+				assert.Equal(t, tc.expErrorFolder, c.ErrorFolder)
 			}
 		})
 	}
 }
 
+func TestLoadConfOutputTimezone(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Paris", c.OutputTimezone)
+
+	c, err = loadConf("../testdata/conf/default.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "", c.OutputTimezone)
+}
+
+func TestLoadConfDestination(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "{date:2006}/{date:01}/{meta:Model|unknown camera}/{name}{ext:lower}", c.Destination)
+
+	c, err = loadConf("../testdata/conf/default.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "", c.Destination)
+}
+
+func TestLoadConfRename(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "{date:20060102_150405}{subsec:3}_{counter:4}{ext:lower}", c.Rename)
+
+	c, err = loadConf("../testdata/conf/default.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "", c.Rename)
+}
+
+func TestLoadConfLocale(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "fr", c.Locale)
+
+	c, err = loadConf("../testdata/conf/default.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "", c.Locale)
+}
+
+func TestLoadConfFiscalYearStart(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Equal(t, 10, c.FiscalYearStart)
+
+	c, err = loadConf("../testdata/conf/default.json")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, c.FiscalYearStart)
+}
+
+func TestLoadConfCompanions(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Equal(t, companionRules{
+		{Primaries: []string{"cr2", "jpg"}, Companions: []string{"jpg", "xmp"}},
+		{Primaries: []string{"heic"}, Companions: []string{"mov"}},
+	}, c.Companions)
+
+	c, err = loadConf("../testdata/conf/default.json")
+	assert.Nil(t, err)
+	assert.Empty(t, c.Companions)
+
+	c, err = loadConf("../testdata/conf/defaultCompanions.json")
+	assert.Nil(t, err)
+	assert.Len(t, c.Companions, len(classifier.DefaultCompanionRules))
+	for i, r := range classifier.DefaultCompanionRules {
+		assert.Equal(t, companionRule{Primaries: r.Primaries, Companions: r.Companions}, c.Companions[i])
+	}
+
+	_, err = loadConf("../testdata/conf/unknownCompanions.json")
+	assert.NotNil(t, err)
+}
+
+func TestLoadConfClockCorrections(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Len(t, c.corrections, 2)
+
+	cc := c.corrections[0]
+	assert.Equal(t, "EOS 5D DST", cc.Name)
+	assert.Equal(t, map[string]string{"Model": "Canon EOS 5D", "SerialNumber": "1234"}, cc.Match)
+	assert.True(t, time.Date(2019, 3, 31, 0, 0, 0, 0, time.UTC).Equal(cc.From))
+	assert.True(t, time.Date(2019, 10, 27, 1, 0, 0, 0, time.UTC).Equal(cc.To))
+	assert.Equal(t, time.Hour, cc.Shift)
+
+	cc = c.corrections[1]
+	assert.Equal(t, 1, cc.Years)
+	assert.True(t, cc.From.IsZero())
+	assert.True(t, cc.To.IsZero())
+}
+
+func TestLoadConfRules(t *testing.T) {
+	c, err := loadConf("../testdata/conf/nominal.json")
+	assert.Nil(t, err)
+	assert.Len(t, c.Rules, 2)
+	assert.Equal(t, classifier.Rule{
+		Name:        "screenshots",
+		Match:       classifier.RuleMatch{Paths: []string{"Screenshot_*"}, MimeTypes: []string{"image/*"}},
+		DateFields:  []classifier.DateField{{Source: "filename"}},
+		Destination: "screenshots/{date:2006}/{file}",
+	}, c.Rules[0].rule())
+	assert.Equal(t, classifier.Rule{
+		Name:             "videos",
+		Match:            classifier.RuleMatch{Extensions: []string{"mp4", "mov"}, Tags: map[string]string{"Make": "Apple"}, MinSize: 1024, MaxSize: 1073741824},
+		DateFields:       []classifier.DateField{},
+		OutputDateFormat: "2006",
+		Rename:           "{name}_{counter}{ext}",
+		Operation:        "copy",
+	}, c.Rules[1].rule())
+
+	c, err = loadConf("../testdata/conf/default.json")
+	assert.Nil(t, err)
+	assert.Empty(t, c.Rules)
+}
+
+func TestClockCorrectionConf(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		cc       clockCorrection
+		expError bool
+	}{
+		{"nominal", clockCorrection{From: "2019-01-01T00:00:00Z", To: "2020-01-01T00:00:00Z", Shift: "-90m"}, false},
+		{"invalidFrom", clockCorrection{From: "2019-01-01"}, true},
+		{"invalidTo", clockCorrection{To: "tomorrow"}, true},
+		{"invalidShift", clockCorrection{Shift: "1 hour"}, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			_, err := tc.cc.correction()
+			assert.Equal(t, tc.expError, err != nil)
+		})
+	}
+}
+
+func TestDateFieldPatterns(t *testing.T) {
+	assert.Equal(t, []string{"a"}, dateField{Pattern: "a"}.patterns())
+	assert.Equal(t, []string{"a", "b", "c"}, dateField{Pattern: "a", Patterns: []string{"b", "c"}}.patterns())
+	assert.Equal(t, []string{"b", "c"}, dateField{Patterns: []string{"b", "c"}}.patterns())
+	assert.Empty(t, dateField{}.patterns())
+}
+
 func TestDoMainFailure(t *testing.T) {
 	var tcs = []struct {
 		tcID    string
@@ -51,6 +221,8 @@ func TestDoMainFailure(t *testing.T) {
 		{"no confFile", []string{"-s", "/tmp", "-d", "/tmp"}, retConfFailure},
 		{"no source", []string{"-c", "../testdata/conf/default.json", "-d", "/tmp"}, retConfFailure},
 		{"no destination", []string{"-c", "../testdata/conf/default.json", "-s", "/tmp"}, retConfFailure},
+		{"unknown plan format", []string{"-f", "xml", "-c", "../testdata/conf/default.json", "-s", "/tmp", "-d", "/tmp"}, retConfFailure},
+		{"unknown extractor", []string{"-c", "../testdata/conf/unknownExtractor.json", "-s", "/tmp", "-d", "/tmp"}, retConfFailure},
 	}
 
 	for _, tc := range tcs {
@@ -60,3 +232,15 @@ func TestDoMainFailure(t *testing.T) {
 		})
 	}
 }
+
+func TestDoMainDryRun(t *testing.T) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("exiftool is not installed")
+	}
+	for _, f := range []string{planFormatText, planFormatJSON} {
+		t.Run(f, func(t *testing.T) {
+			ret := doMain([]string{"dispatcher", "-n", "-f", f, "-c", "../testdata/conf/default.json", "-s", "../testdata/input", "-d", "/tmp/out"})
+			assert.Equal(t, retOk, ret)
+		})
+	}
+}
@@ -6,4 +6,5 @@ require (
 	github.com/barasher/go-exiftool v1.0.0
 	github.com/sirupsen/logrus v1.4.1
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/sys v0.0.0-20180905080454-ebe1bf3edb33
 )
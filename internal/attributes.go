@@ -0,0 +1,50 @@
+package classifier
+
+import (
+	"fmt"
+	"os"
+)
+
+// Preservation lists the attributes of the source files that are carried over when files are copied
+type Preservation struct {
+	// Times preserves the access and modification times
+	Times bool
+	// Mode preserves the permission bits
+	Mode bool
+	// Ownership preserves the owner and the group (only when running as root)
+	Ownership bool
+	// Xattrs preserves the extended attributes
+	Xattrs bool
+}
+
+// PreserveAll preserves every supported attribute
+var PreserveAll = Preservation{Times: true, Mode: true, Ownership: true, Xattrs: true}
+
+const preservedModeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// applyAttributes carries the attributes of the file from (described by fi) over to the file to.
+// The mode is applied after the extended attributes, which can't be written once the file is read-only,
+// and the times are applied last since the other changes may update them.
+func applyAttributes(fi os.FileInfo, from string, to string, p Preservation) error {
+	if p.Ownership && os.Geteuid() == 0 {
+		if err := chown(fi, to); err != nil {
+			return fmt.Errorf("error while preserving ownership: %v", err)
+		}
+	}
+	if p.Xattrs {
+		if err := copyXattrs(from, to); err != nil {
+			return fmt.Errorf("error while preserving extended attributes: %v", err)
+		}
+	}
+	if p.Mode {
+		if err := os.Chmod(to, fi.Mode()&preservedModeBits); err != nil {
+			return fmt.Errorf("error while preserving mode: %v", err)
+		}
+	}
+	if p.Times {
+		if err := os.Chtimes(to, accessTime(fi), fi.ModTime()); err != nil {
+			return fmt.Errorf("error while preserving times: %v", err)
+		}
+	}
+	return nil
+}
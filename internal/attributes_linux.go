@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+package classifier
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func accessTime(fi os.FileInfo) time.Time {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Unix())
+	}
+	return fi.ModTime()
+}
+
+func chown(fi os.FileInfo, to string) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return os.Lchown(to, int(st.Uid), int(st.Gid))
+}
+
+func copyXattrs(from string, to string) error {
+	names, err := xattrNames(from)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			return nil
+		}
+		return err
+	}
+	for _, name := range names {
+		value, err := xattrValue(from, name)
+		if err != nil {
+			return err
+		}
+		if err := syscall.Setxattr(to, name, value, 0); err != nil {
+			if err == syscall.ENOTSUP || err == syscall.EPERM {
+				logrus.Debugf("extended attribute %v of %v can't be preserved: %v", name, from, err)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func xattrNames(path string) ([]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+func xattrValue(path string, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil || size == 0 {
+		return []byte{}, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
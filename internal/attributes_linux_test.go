@@ -0,0 +1,48 @@
+package classifier
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyPreservingXattrs(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestCopyPreservingXattrs"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/a.jpg", "content")
+	assert.Nil(t, os.MkdirAll(dir+"/out", 0777))
+	if err := syscall.Setxattr(dir+"/in/a.jpg", "user.fdd", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+
+	assert.Nil(t, copyPreserving(dir+"/in/a.jpg", dir+"/out/a.jpg", Preservation{Xattrs: true}))
+	v, err := xattrValue(dir+"/out/a.jpg", "user.fdd")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", string(v))
+
+	assert.Nil(t, copyPreserving(dir+"/in/a.jpg", dir+"/out/b.jpg", Preservation{}))
+	names, err := xattrNames(dir + "/out/b.jpg")
+	assert.Nil(t, err)
+	assert.NotContains(t, names, "user.fdd")
+}
+
+func TestCopyPreservingXattrsReadOnly(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestCopyPreservingXattrsReadOnly"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/a.jpg", "content")
+	assert.Nil(t, os.MkdirAll(dir+"/out", 0777))
+	if err := syscall.Setxattr(dir+"/in/a.jpg", "user.fdd", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes not supported: %v", err)
+	}
+	assert.Nil(t, os.Chmod(dir+"/in/a.jpg", 0444))
+
+	assert.Nil(t, copyPreserving(dir+"/in/a.jpg", dir+"/out/a.jpg", Preservation{Mode: true, Xattrs: true}))
+	v, err := xattrValue(dir+"/out/a.jpg", "user.fdd")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", string(v))
+	fi, err := os.Stat(dir + "/out/a.jpg")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0444), fi.Mode().Perm())
+}
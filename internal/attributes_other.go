@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package classifier
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime can't be read portably, the modification time is used instead
+func accessTime(fi os.FileInfo) time.Time {
+	return fi.ModTime()
+}
+
+// chown is only supported on linux
+func chown(fi os.FileInfo, to string) error {
+	return nil
+}
+
+// copyXattrs is only supported on linux
+func copyXattrs(from string, to string) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package classifier
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyPreserving(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestCopyPreserving"
+	mtime := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	var tcs = []struct {
+		tcID     string
+		p        Preservation
+		expMode  os.FileMode
+		expMtime bool
+	}{
+		{"all", PreserveAll, 0600, true},
+		{"none", Preservation{}, copiedFileMode, false},
+		{"timesOnly", Preservation{Times: true}, copiedFileMode, true},
+		{"modeOnly", Preservation{Mode: true}, 0600, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Nil(t, os.RemoveAll(dir))
+			writeFile(t, dir+"/in/a.jpg", "content")
+			assert.Nil(t, os.Chmod(dir+"/in/a.jpg", 0600))
+			assert.Nil(t, os.Chtimes(dir+"/in/a.jpg", mtime, mtime))
+			assert.Nil(t, os.MkdirAll(dir+"/out", 0777))
+
+			assert.Nil(t, copyPreserving(dir+"/in/a.jpg", dir+"/out/a.jpg", tc.p))
+			fi, err := os.Stat(dir + "/out/a.jpg")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expMode, fi.Mode().Perm())
+			assert.Equal(t, tc.expMtime, fi.ModTime().Equal(mtime))
+		})
+	}
+}
@@ -3,35 +3,79 @@ package classifier
 import (
 	"context"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/barasher/go-exiftool"
-
 	"github.com/sirupsen/logrus"
 )
 
+// moveAction describes what has to be done with a file : it has to be moved to the "to" folder or,
+// if err is not nil, it can't be classified for the provided reason. date and fields are the date
+// and the metadata the destination template can refer to. lowConfidence is set when the date is only
+// an approximation (filesystem timestamp). correction describes the clock correction applied to the
+// date, if any. The companions follow the file. rule is the rule applied to the file, nil for the
+// default rule.
 type moveAction struct {
-	from string
-	to   string
+	from          string
+	to            string
+	date          time.Time
+	fields        map[string]interface{}
+	err           error
+	reason        string
+	lowConfidence bool
+	correction    string
+	companions    []string
+	rule          *rule
 }
 
+// Reasons why a file can't be classified
+const (
+	reasonNoDate          = "no date"
+	reasonInvalidDate     = "invalid date"
+	reasonExtractionError = "extraction error"
+)
+
 // Classifier is a structure modeling the classifying tool
 type Classifier struct {
-	batchSize        uint
-	outputDateFormat string
+	batchSize         uint
+	workers           uint
+	dateFields        []DateField
+	outputDateFormat  string
+	extractorFactory  ExtractorFactory
+	collisionPolicy   string
+	operation         string
+	preserve          Preservation
+	unknownDateFolder string
+	errorFolder       string
+	companionRules    []CompanionRule
+	outputLocation    *time.Location
+	clockCorrections  []ClockCorrection
+	destination       *pathTemplate
+	rename            *pathTemplate
+	rules             []rule
+	locale            *locale
+	fiscalYearStart   time.Month
 }
 
-var dateFields = make(map[string]string)
-
-var errNoDateFount = fmt.Errorf("No data found")
-
 // NewClassifier instanciates a new classifier with several optionnal functions
 func NewClassifier(classOpts ...func(*Classifier) error) (*Classifier, error) {
-	c := Classifier{batchSize: 10, outputDateFormat: "2006_01"}
+	c := Classifier{
+		batchSize:        10,
+		workers:          1,
+		outputDateFormat: "2006_01",
+		extractorFactory: NewExiftoolExtractor,
+		collisionPolicy:  CollisionRename,
+		operation:        OperationMove,
+		preserve:         PreserveAll,
+		locale:           locales[defaultLocale],
+		fiscalYearStart:  time.January,
+	}
 	for _, opt := range classOpts {
 		if err := opt(&c); err != nil {
 			return nil, fmt.Errorf("error when configuring classifier: %v", err)
@@ -48,54 +92,157 @@ func OptBatchSize(size uint) func(*Classifier) error {
 	}
 }
 
-// OptDateFields specifies which tags must be considered as classifying date
-func OptDateFields(fields map[string]string) func(*Classifier) error {
+// OptWorkers specifies how many metadata extractions can run in parallel
+func OptWorkers(workers uint) func(*Classifier) error {
 	return func(c *Classifier) error {
-		for f, p := range fields {
-			dateFields[f] = p
+		if workers < 1 {
+			return fmt.Errorf("at least one worker is required")
 		}
+		c.workers = workers
 		return nil
 	}
 }
 
-// OptOutputDateFormat specifies the output date format
-func OptOutputDateFormat(format string) func(*Classifier) error {
+// OptDateFields specifies which tags must be considered as classifying date, by decreasing priority
+func OptDateFields(fields []DateField) func(*Classifier) error {
 	return func(c *Classifier) error {
-		c.outputDateFormat = format
+		c.dateFields = append([]DateField{}, fields...)
+		for i := range c.dateFields {
+			if err := c.dateFields[i].init(); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
 }
 
-func (cl *Classifier) guessDate(fm exiftool.FileMetadata) (time.Time, error) {
-	for field, pattern := range dateFields {
-		if val, found := fm.Fields[field]; found {
-			t, err := time.Parse(pattern, val.(string))
-			if err != nil {
-				return time.Time{}, fmt.Errorf("error when parsing date %v: %v", val.(string), err)
+// OptMetadataExtractor specifies how the metadata extractors are instanciated
+func OptMetadataExtractor(factory ExtractorFactory) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if factory == nil {
+			return fmt.Errorf("no metadata extractor factory provided")
+		}
+		c.extractorFactory = factory
+		return nil
+	}
+}
+
+// OptCollisionPolicy specifies what happens when a file with the same name already exists in the
+// destination folder (CollisionSkip, CollisionOverwrite, CollisionRename, CollisionRenameWithHash, CollisionFail)
+func OptCollisionPolicy(policy string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if !collisionPolicies[policy] {
+			return fmt.Errorf("unknown collision policy %v", policy)
+		}
+		c.collisionPolicy = policy
+		return nil
+	}
+}
+
+// OptOperation specifies how the files are dispatched (OperationMove, OperationCopy, OperationHardlink,
+// OperationSymlink, OperationReflink)
+func OptOperation(operation string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if !operations[operation] {
+			return fmt.Errorf("unknown operation %v", operation)
+		}
+		c.operation = operation
+		return nil
+	}
+}
+
+// OptPreserve specifies which attributes are carried over when the file contents are duplicated
+func OptPreserve(p Preservation) func(*Classifier) error {
+	return func(c *Classifier) error {
+		c.preserve = p
+		return nil
+	}
+}
+
+// OptUnknownDateFolder specifies the folder where the files without date are dispatched. Relative
+// folders are resolved against the output folder. If empty, these files are left in place.
+func OptUnknownDateFolder(folder string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		c.unknownDateFolder = folder
+		return nil
+	}
+}
+
+// OptErrorFolder specifies the folder where the files whose date can't be extracted are dispatched.
+// Relative folders are resolved against the output folder. If empty, these files are left in place.
+func OptErrorFolder(folder string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		c.errorFolder = folder
+		return nil
+	}
+}
+
+// OptCompanionRules specifies how the companion files (sidecars, RAW+JPEG pairs, Live Photos, ...) are
+// grouped with their primary file. Companions are not classified on their own but follow their primary file.
+func OptCompanionRules(rules []CompanionRule) func(*Classifier) error {
+	return func(c *Classifier) error {
+		for _, r := range rules {
+			if err := r.check(); err != nil {
+				return err
 			}
-			return t, nil
 		}
+		c.companionRules = append([]CompanionRule{}, rules...)
+		return nil
+	}
+}
+
+// OptOutputDateFormat specifies the output date format
+func OptOutputDateFormat(format string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		c.outputDateFormat = format
+		return nil
 	}
-	return time.Time{}, errNoDateFount
 }
 
 // Classify classifies the inputFolder and stores the results outputFolder
 func (cl *Classifier) Classify(inputFolder string, outputFolder string) error {
+	canceled := cl.process(inputFolder, func(ctx context.Context, cancel context.CancelFunc, actionChan chan moveAction, wgGlobal *sync.WaitGroup) {
+		cl.moveFiles(ctx, cancel, inputFolder, outputFolder, actionChan, wgGlobal)
+	})
+	if canceled {
+		return fmt.Errorf("classification canceled")
+	}
+	return nil
+}
+
+// Plan computes what the classification of inputFolder to outputFolder would do, without touching any file
+func (cl *Classifier) Plan(inputFolder string, outputFolder string) (Plan, error) {
+	p := Plan{Moves: []PlannedMove{}, Unclassified: []UnclassifiedFile{}}
+	canceled := cl.process(inputFolder, func(ctx context.Context, cancel context.CancelFunc, actionChan chan moveAction, wgGlobal *sync.WaitGroup) {
+		cl.planFiles(ctx, cancel, inputFolder, outputFolder, actionChan, &p, wgGlobal)
+	})
+	if canceled {
+		return p, fmt.Errorf("planning canceled")
+	}
+	p.sort()
+	return p, nil
+}
+
+// process runs the classification pipeline, the actions being handled by the consume function. It
+// returns true if the pipeline has been canceled.
+func (cl *Classifier) process(inputFolder string, consume func(context.Context, context.CancelFunc, chan moveAction, *sync.WaitGroup)) bool {
 	ctx, cancel := context.WithCancel(context.Background())
-	filesChan := make(chan string, cl.batchSize*2)
+	defer cancel()
+	filesChan := make(chan fileGroup, cl.batchSize*2)
 	actionChan := make(chan moveAction, cl.batchSize)
 	var wgGlobal sync.WaitGroup
 	wgGlobal.Add(3)
 
 	go cl.listFiles(ctx, cancel, inputFolder, filesChan, &wgGlobal)
 	go cl.getMoveActions(ctx, cancel, filesChan, actionChan, &wgGlobal)
-	go cl.moveFiles(ctx, cancel, outputFolder, actionChan, &wgGlobal)
+	go consume(ctx, cancel, actionChan, &wgGlobal)
 
 	wgGlobal.Wait()
-	return nil
+	return ctx.Err() != nil
 }
 
-func (cl *Classifier) listFiles(ctx context.Context, cancel context.CancelFunc, inputFolder string, filesChan chan string, wgGlobal *sync.WaitGroup) {
+// listFiles browses the input folder, the files of each folder being grouped with their companions
+func (cl *Classifier) listFiles(ctx context.Context, cancel context.CancelFunc, inputFolder string, filesChan chan fileGroup, wgGlobal *sync.WaitGroup) {
 	defer wgGlobal.Done()
 	defer close(filesChan)
 	fileCount := 0
@@ -105,13 +252,31 @@ func (cl *Classifier) listFiles(ctx context.Context, cancel context.CancelFunc,
 		if err != nil {
 			return fmt.Errorf("error when browsing file %v: %v", path, err)
 		}
+		files := []string{}
 		if !info.IsDir() {
+			if path != inputFolder {
+				return nil
+			}
+			files = append(files, path)
+		} else {
+			fis, err := ioutil.ReadDir(path)
+			if err != nil {
+				return fmt.Errorf("error when browsing folder %v: %v", path, err)
+			}
+			for _, fi := range fis {
+				if !fi.IsDir() {
+					files = append(files, filepath.Join(path, fi.Name()))
+				}
+			}
+		}
+		for _, g := range cl.groupFiles(files) {
+			g.rel = relativePath(inputFolder, g.primary)
 			select {
 			case <-ctx.Done():
 				return nil
-			case filesChan <- path:
-				fileCount++
-				logrus.Debugf("New file to extract: %v", path)
+			case filesChan <- g:
+				fileCount += 1 + len(g.companions)
+				logrus.Debugf("New file to extract: %v (companions: %v)", g.primary, g.companions)
 			}
 		}
 		return nil
@@ -124,74 +289,130 @@ func (cl *Classifier) listFiles(ctx context.Context, cancel context.CancelFunc,
 	logrus.Infof("%v file(s) found", fileCount)
 }
 
-func (cl *Classifier) getMoveActions(ctx context.Context, cancel context.CancelFunc, filesChan chan string, actionChan chan moveAction, wgGlobal *sync.WaitGroup) {
+func (cl *Classifier) getMoveActions(ctx context.Context, cancel context.CancelFunc, filesChan chan fileGroup, actionChan chan moveAction, wgGlobal *sync.WaitGroup) {
 	defer wgGlobal.Done()
 	defer close(actionChan)
-	files := make([]string, cl.batchSize)
-	i := uint(0)
-	actionCount := 0
+	batchChan := make(chan []fileGroup, cl.workers)
+	var actionCount int64
+	var wgWorkers sync.WaitGroup
+	wgWorkers.Add(int(cl.workers))
+	for w := uint(0); w < cl.workers; w++ {
+		go cl.extractMetadata(ctx, cancel, batchChan, actionChan, &actionCount, &wgWorkers)
+	}
+
+	cl.batchFiles(ctx, filesChan, batchChan)
+	close(batchChan)
+	wgWorkers.Wait()
+	logrus.Infof("%v move(s)", atomic.LoadInt64(&actionCount))
+}
 
+func (cl *Classifier) batchFiles(ctx context.Context, filesChan chan fileGroup, batchChan chan []fileGroup) {
+	files := make([]fileGroup, 0, cl.batchSize)
 	for f := range filesChan {
+		files = append(files, f)
+		if uint(len(files)) < cl.batchSize {
+			continue
+		}
 		select {
 		case <-ctx.Done():
 			logrus.Infof("getMoveAction canceled")
 			return
-		default:
-			files[i] = f
-			if i == cl.batchSize-1 {
-				count, err2 := cl.buildActionsAndPush(ctx, files, actionChan)
-				if err2 != nil {
-					cancel()
-					logrus.Errorf("error while pushing: %v", err2)
-					return
-				}
-				actionCount += count
-				i = 0
-			} else {
-				i++
-			}
+		case batchChan <- files:
+			files = make([]fileGroup, 0, cl.batchSize)
 		}
 	}
 
-	if i > 0 {
-		count, err2 := cl.buildActionsAndPush(ctx, files[:i], actionChan)
-		if err2 != nil {
-			cancel()
-			logrus.Errorf("error while pushing: %v", err2)
-			return
+	if len(files) > 0 {
+		select {
+		case <-ctx.Done():
+			logrus.Infof("getMoveAction canceled")
+		case batchChan <- files:
 		}
-		actionCount += count
 	}
-	logrus.Infof("%v move(s)", actionCount)
 }
 
-func (cl *Classifier) buildActionsAndPush(ctx context.Context, files []string, actionChan chan moveAction) (int, error) {
-	logrus.Debugf("Build action batch: %v", files)
-	e, err := exiftool.NewExiftool()
+func (cl *Classifier) extractMetadata(ctx context.Context, cancel context.CancelFunc, batchChan chan []fileGroup, actionChan chan moveAction, actionCount *int64, wgWorkers *sync.WaitGroup) {
+	defer wgWorkers.Done()
+	e, err := cl.extractorFactory()
 	if err != nil {
-		return 0, fmt.Errorf("error while intializing exiftool: %v", err)
+		cancel()
+		logrus.Errorf("error while intializing metadata extractor: %v", err)
+		return
+	}
+	defer func() {
+		if err := e.Close(); err != nil {
+			logrus.Errorf("error while closing metadata extractor: %v", err)
+		}
+	}()
+
+	for files := range batchChan {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			count, err := cl.buildActionsAndPush(ctx, e, files, actionChan)
+			if err != nil {
+				cancel()
+				logrus.Errorf("error while pushing: %v", err)
+				return
+			}
+			atomic.AddInt64(actionCount, int64(count))
+		}
 	}
-	defer e.Close()
+}
+
+// buildActionsAndPush extracts the metadata of the primary files of the groups and pushes the resulting
+// actions, the companions following their primary file
+func (cl *Classifier) buildActionsAndPush(ctx context.Context, e MetadataExtractor, groups []fileGroup, actionChan chan moveAction) (int, error) {
+	files := make([]string, len(groups))
+	for i, g := range groups {
+		files[i] = g.primary
+	}
+	logrus.Debugf("Build action batch: %v", files)
 	fms := e.ExtractMetadata(files...)
 
 	actionCount := 0
-	for _, fm := range fms {
+	for i, fm := range fms {
 		select {
 		case <-ctx.Done():
 			return 0, fmt.Errorf("Canceled")
 		default:
+			companions := groups[i].companions
+			r := cl.matchingRule(fm, groups[i].rel)
+			if r != nil {
+				logrus.Debugf("%v matches rule %v", fm.File, r.Name)
+			}
 			if fm.Err != nil {
 				logrus.Errorf("error while extracting metadata from  %v: %v", fm.File, fm.Err)
+				actionChan <- moveAction{from: fm.File, err: fmt.Errorf("error while extracting metadata: %v", fm.Err), reason: reasonExtractionError, companions: companions, rule: r}
 				continue
 			}
-			if d, err := cl.guessDate(fm); err != nil {
+			s := cl.settings(r)
+			if d, df, err := guessDate(fm, s.dateFields); err != nil {
+				reason := reasonNoDate
 				if err != errNoDateFount {
 					logrus.Errorf("error while generating moveAction for %v: %v", fm.File, err)
+					reason = reasonInvalidDate
 				}
+				actionChan <- moveAction{from: fm.File, err: err, reason: reason, companions: companions, rule: r}
 			} else {
+				if df.lowConfidence() {
+					logrus.Infof("%v dated from its %v (low confidence)", fm.File, df.name())
+				}
+				corrected, correction := cl.correctDate(fm, d)
+				if correction != "" {
+					logrus.Infof("%v date corrected (%v): %v -> %v", fm.File, correction, d, corrected)
+				}
+				d = cl.outputDate(corrected)
 				actionChan <- moveAction{
-					from: fm.File,
-					to:   d.Format(cl.outputDateFormat),
+					from:          fm.File,
+					to:            d.Format(s.outputDateFormat),
+					date:          d,
+					fields:        fm.Fields,
+					lowConfidence: df.lowConfidence(),
+					correction:    correction,
+					companions:    companions,
+					rule:          r,
 				}
 				actionCount++
 			}
@@ -201,53 +422,247 @@ func (cl *Classifier) buildActionsAndPush(ctx context.Context, files []string, a
 	return actionCount, nil
 }
 
-func (cl *Classifier) moveFiles(ctx context.Context, cancel context.CancelFunc, outputFolder string, actionChan chan moveAction, wgGlobal *sync.WaitGroup) {
+func (cl *Classifier) moveFiles(ctx context.Context, cancel context.CancelFunc, inputFolder string, outputFolder string, actionChan chan moveAction, wgGlobal *sync.WaitGroup) {
 	defer wgGlobal.Done()
 	moveCount := 0
+	methodCounts := make(map[string]int)
+	reasonCounts := make(map[string]int)
+	duplicateCount := 0
+	skipCount := 0
+	lowConfidenceCount := 0
+	correctionCount := 0
+	companionCount := 0
 	dirs := make(map[string]bool)
+
+	// dispatchTo dispatches a single file with the operation to its final destination
+	dispatchTo := func(operation string, from string, to string) bool {
+		if dir := filepath.Dir(to); !dirs[dir] {
+			if err := os.MkdirAll(dir, 0777); err != nil {
+				logrus.Errorf("error when creating output folder: %v", err)
+				return false
+			}
+			dirs[dir] = true
+		}
+		logrus.Debugf("Dispatching (%v) %v to %v", operation, from, to)
+		how, err := dispatch(operation, cl.preserve, from, to)
+		if err != nil {
+			logrus.Errorf("error when dispatching (%v) %v to %v: %v", operation, from, to, err)
+			return false
+		}
+		moveCount++
+		methodCounts[how]++
+		return true
+	}
+
+	// place dispatches a file and its companions with the operation. The collisions are resolved for the
+	// group as a whole, so that the companions keep the name of the file. If next is not nil, it builds the
+	// numbered targets among which the first free one is used. It returns false if the file is not dispatched.
+	place := func(operation string, from string, target string, next func(int) string, companions []string) bool {
+		occupant := groupOccupant(existingOccupant, from, companions)
+		var to string
+		var err error
+		if next != nil {
+			to, err = firstFree(from, occupant, next)
+		} else {
+			to, err = cl.resolveCollision(from, target, occupant)
+		}
+		if err != nil {
+			switch err {
+			case errDuplicate:
+				duplicateCount++
+				logrus.Infof("%v not moved: %v", from, err)
+			case errCollisionSkipped:
+				skipCount++
+				logrus.Warnf("%v not moved: %v", from, err)
+			case errCollision:
+				cancel()
+				logrus.Errorf("%v can't be moved to %v: %v", from, target, err)
+			default:
+				logrus.Errorf("error when checking destination of %v: %v", from, err)
+			}
+			return false
+		}
+		if !dispatchTo(operation, from, to) {
+			return false
+		}
+		for _, c := range companions {
+			if dispatchTo(operation, c, companionTarget(from, to, c)) {
+				companionCount++
+			}
+		}
+		return true
+	}
+
 	for ma := range actionChan {
 		select {
 		case <-ctx.Done():
 			logrus.Infof("moveFiles canceled")
 		default:
-			if _, found := dirs[ma.to]; !found {
-				if err := os.MkdirAll(filepath.Join(outputFolder, ma.to), 0777); err != nil {
-					logrus.Errorf("error when creating output folder: %v", err)
-					continue
+			if ma.err != nil {
+				reasonCounts[ma.reason]++
+			}
+			target, found := cl.targetPath(inputFolder, outputFolder, ma)
+			if !found {
+				continue
+			}
+			operation := cl.settings(ma.rule).operation
+			if !place(operation, ma.from, target, cl.numberedTargets(inputFolder, outputFolder, ma), ma.companions) {
+				continue
+			}
+			if ma.lowConfidence {
+				lowConfidenceCount++
+			}
+			if ma.correction != "" {
+				correctionCount++
+			}
+		}
+	}
+	logrus.Infof("%v dispatched file(s) (%v), %v companion(s), %v duplicate(s), %v skipped file(s), %v low confidence date(s), %v corrected date(s)", moveCount, formatCounts(methodCounts), companionCount, duplicateCount, skipCount, lowConfidenceCount, correctionCount)
+	logrus.Infof("%v unclassified file(s) (%v)", sumCounts(reasonCounts), formatCounts(reasonCounts))
+}
+
+func (cl *Classifier) planFiles(ctx context.Context, cancel context.CancelFunc, inputFolder string, outputFolder string, actionChan chan moveAction, p *Plan, wgGlobal *sync.WaitGroup) {
+	defer wgGlobal.Done()
+	planned := make(map[string]string)
+	occupant := func(to string) (string, bool) {
+		if from, found := planned[to]; found {
+			return from, true
+		}
+		return existingOccupant(to)
+	}
+
+	// place plans the dispatch of a file and its companions and returns where the file would be stored.
+	// The collisions are resolved for the group as a whole, so that the companions keep the name of the file.
+	// If next is not nil, it builds the numbered targets among which the first free one is used.
+	place := func(from string, target string, next func(int) string, companions []string) (string, bool) {
+		occupied := groupOccupant(occupant, from, companions)
+		var to string
+		var err error
+		if next != nil {
+			to, err = firstFree(from, occupied, next)
+		} else {
+			to, err = cl.resolveCollision(from, target, occupied)
+		}
+		if err != nil {
+			if err == errCollision {
+				cancel()
+				logrus.Errorf("%v can't be moved to %v: %v", from, target, err)
+			}
+			p.Unclassified = append(p.Unclassified, UnclassifiedFile{File: from, Reason: err.Error()})
+			return "", false
+		}
+		planned[to] = from
+		for _, c := range companions {
+			planned[companionTarget(from, to, c)] = c
+		}
+		return to, true
+	}
+
+	for ma := range actionChan {
+		select {
+		case <-ctx.Done():
+			logrus.Infof("planFiles canceled")
+		default:
+			target, found := cl.targetPath(inputFolder, outputFolder, ma)
+			if !found {
+				p.Unclassified = append(p.Unclassified, UnclassifiedFile{File: ma.from, Reason: ma.err.Error()})
+			}
+			to, placed := "", false
+			if found {
+				to, placed = place(ma.from, target, cl.numberedTargets(inputFolder, outputFolder, ma), ma.companions)
+			}
+			if !placed {
+				for _, c := range ma.companions {
+					p.Unclassified = append(p.Unclassified, UnclassifiedFile{File: c, Reason: fmt.Sprintf("companion of %v", ma.from)})
 				}
-				dirs[ma.to] = true
+				continue
 			}
-			_, f := filepath.Split(ma.from)
-			to := filepath.Join(outputFolder, ma.to, f)
-			logrus.Debugf("Moving %v to %v", ma.from, to)
-			if err := move(ma.from, to); err != nil {
-				logrus.Errorf("error when moving %v to %v: %v", ma.from, to, err)
-			} else {
-				moveCount++
+			p.Moves = append(p.Moves, PlannedMove{From: ma.from, To: to, Reason: ma.reason, LowConfidence: ma.lowConfidence, Correction: ma.correction})
+			for _, c := range ma.companions {
+				p.Moves = append(p.Moves, PlannedMove{From: c, To: companionTarget(ma.from, to, c), Reason: ma.reason, LowConfidence: ma.lowConfidence, Correction: ma.correction, CompanionOf: ma.from})
 			}
 		}
 	}
-	logrus.Infof("%v moved file(s)", moveCount)
 }
 
-func copy(from, to string) error {
-	source, err := os.Open(from)
-	if err != nil {
-		return err
+// formatCounts formats counters as "key1: n1, key2: n2", sorted by key
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
 	}
-	defer source.Close()
-	destination, err := os.Create(to)
-	if err != nil {
-		return err
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%v: %v", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sumCounts(counts map[string]int) int {
+	sum := 0
+	for _, c := range counts {
+		sum += c
+	}
+	return sum
+}
+
+// targetPath computes where a file has to be dispatched, according to the destination template or
+// to the output date format. Unclassifiable files are dispatched to the unknown date or error folders,
+// keeping their path relative to the input folder. It returns false if the file has to be left in place.
+func (cl *Classifier) targetPath(inputFolder string, outputFolder string, ma moveAction) (string, bool) {
+	return cl.numberedTargetPath(inputFolder, outputFolder, ma, 1)
+}
+
+// numberedTargetPath computes where a file has to be dispatched, the {counter} tokens of the templates
+// being replaced by counter. The file name is computed by the rename template, if any. The templates
+// are the ones of the rule applied to the file.
+func (cl *Classifier) numberedTargetPath(inputFolder string, outputFolder string, ma moveAction, counter int) (string, bool) {
+	if ma.err == nil {
+		s := cl.settings(ma.rule)
+		ctx := templateContext{date: ma.date, fields: ma.fields, from: ma.from, rel: filepath.Dir(relativePath(inputFolder, ma.from)), counter: counter, locale: cl.locale, fiscalYearStart: cl.fiscalYearStart}
+		target := filepath.Join(outputFolder, ma.to, filepath.Base(ma.from))
+		if s.destination != nil {
+			target = filepath.Join(outputFolder, s.destination.evaluate(ctx))
+		}
+		if s.rename != nil {
+			target = filepath.Join(filepath.Dir(target), s.rename.evaluate(ctx))
+		}
+		return target, true
+	}
+	folder := cl.errorFolder
+	if ma.reason == reasonNoDate {
+		folder = cl.unknownDateFolder
+	}
+	if folder == "" {
+		return "", false
+	}
+	if !filepath.IsAbs(folder) {
+		folder = filepath.Join(outputFolder, folder)
+	}
+	return filepath.Join(folder, relativePath(inputFolder, ma.from)), true
+}
+
+// numberedTargets returns the function building the numbered targets of a dated file when the
+// templates hold a {counter} token, nil otherwise
+func (cl *Classifier) numberedTargets(inputFolder string, outputFolder string, ma moveAction) func(int) string {
+	if ma.err != nil {
+		return nil
+	}
+	if s := cl.settings(ma.rule); !s.destination.hasCounter() && !s.rename.hasCounter() {
+		return nil
+	}
+	return func(counter int) string {
+		target, _ := cl.numberedTargetPath(inputFolder, outputFolder, ma, counter)
+		return target
 	}
-	defer destination.Close()
-	_, err = io.Copy(destination, source)
-	return err
 }
 
-func move(from, to string) error {
-	if err := copy(from, to); err != nil {
-		return err
+// relativePath returns the path of the file relative to the input folder, or its name if it is outside
+func relativePath(inputFolder string, file string) string {
+	rel, err := filepath.Rel(inputFolder, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.Base(file)
 	}
-	return os.Remove(from)
+	return rel
 }
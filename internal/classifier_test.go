@@ -4,15 +4,48 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
-
-	"github.com/barasher/go-exiftool"
 )
 
+// fakeExtractor is a MetadataExtractor that provides the fields registered for each file basename
+type fakeExtractor struct {
+	fields map[string]map[string]interface{}
+}
+
+func (e *fakeExtractor) ExtractMetadata(files ...string) []FileMetadata {
+	fms := make([]FileMetadata, len(files))
+	for i, f := range files {
+		fms[i].File = f
+		if _, err := os.Stat(f); err != nil {
+			fms[i].Err = err
+			continue
+		}
+		fms[i].Fields = map[string]interface{}{"FileName": filepath.Base(f)}
+		for k, v := range e.fields[filepath.Base(f)] {
+			fms[i].Fields[k] = v
+		}
+	}
+	return fms
+}
+
+func (e *fakeExtractor) Close() error {
+	return nil
+}
+
+func fakeExtractorFactory() (MetadataExtractor, error) {
+	jpg := map[string]interface{}{"CreateDate": "2019:04:04 13:18:03", "Make": "samsung", "Model": "SM-G930F"}
+	return &fakeExtractor{fields: map[string]map[string]interface{}{
+		"20190404_131804.jpg": jpg,
+		"20190404_131805.jpg": jpg,
+		"20190404_131806.jpg": jpg,
+	}}, nil
+}
+
 func checkExist(t *testing.T, path string, shouldExist bool) {
 	_, err := os.Stat(path)
 	if shouldExist {
@@ -47,6 +80,40 @@ func TestNewClassifierError(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestOptMetadataExtractor(t *testing.T) {
+	_, err := NewClassifier(OptMetadataExtractor(nil))
+	assert.NotNil(t, err)
+
+	c, err := NewClassifier(OptMetadataExtractor(fakeExtractorFactory))
+	assert.Nil(t, err)
+	e, err := c.extractorFactory()
+	assert.Nil(t, err)
+	assert.IsType(t, &fakeExtractor{}, e)
+}
+
+func TestOptWorkers(t *testing.T) {
+	_, err := NewClassifier(OptWorkers(0))
+	assert.NotNil(t, err)
+
+	c, err := NewClassifier(OptWorkers(3))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), c.workers)
+}
+
+func TestOptOperation(t *testing.T) {
+	_, err := NewClassifier(OptOperation("unknown"))
+	assert.NotNil(t, err)
+
+	c, err := NewClassifier(OptOperation(OperationCopy))
+	assert.Nil(t, err)
+	assert.Equal(t, OperationCopy, c.operation)
+}
+
+func TestFormatCounts(t *testing.T) {
+	assert.Equal(t, "", formatCounts(map[string]int{}))
+	assert.Equal(t, "a: 2, b: 1", formatCounts(map[string]int{"b": 1, "a": 2}))
+}
+
 func TestListFilesNominal(t *testing.T) {
 	var tcs = []struct {
 		tcID        string
@@ -72,7 +139,7 @@ func TestListFilesNominal(t *testing.T) {
 		t.Run(tc.tcID, func(t *testing.T) {
 
 			ctx, cancel := context.WithCancel(context.TODO())
-			filesChan := make(chan string, 10)
+			filesChan := make(chan fileGroup, 10)
 			var wgGlobal sync.WaitGroup
 			wgGlobal.Add(1)
 
@@ -80,8 +147,8 @@ func TestListFilesNominal(t *testing.T) {
 			c.listFiles(ctx, cancel, tc.folder, filesChan, &wgGlobal)
 
 			files := make([]string, 10)
-			for f := range filesChan {
-				files = append(files, f)
+			for g := range filesChan {
+				files = append(files, g.primary)
 			}
 
 			assert.Subset(t, files, tc.expFiles)
@@ -103,7 +170,8 @@ func TestBuildActionsAndPushCanceled(t *testing.T) {
 
 	cancel()
 	c := buildDefaultClassifier(t, 2)
-	_, err := c.buildActionsAndPush(ctx, []string{"../testdata/input/20190404_131804.jpg"}, actionChan)
+	e, _ := fakeExtractorFactory()
+	_, err := c.buildActionsAndPush(ctx, e, singleGroups("../testdata/input/20190404_131804.jpg"), actionChan)
 	assert.NotNil(t, err)
 }
 
@@ -142,14 +210,15 @@ func TestBuildActionsAndPush(t *testing.T) {
 				actionChan := make(chan moveAction, 10)
 
 				c := buildDefaultClassifier(t, 2)
-				count, err := c.buildActionsAndPush(ctx, tc.files, actionChan)
+				e, _ := fakeExtractorFactory()
+				count, err := c.buildActionsAndPush(ctx, e, singleGroups(tc.files...), actionChan)
 				close(actionChan)
 				assert.Nil(t, err)
 				assert.Equal(t, len(tc.expActions), count)
 
 				actions := []moveAction{}
 				for ma := range actionChan {
-					actions = append(actions, ma)
+					actions = append(actions, withoutTemplateData(t, ma))
 				}
 				assert.Subset(t, actions, tc.expActions)
 
@@ -161,8 +230,8 @@ func TestBuildActionsAndPush(t *testing.T) {
 
 func TestGetMoveActionsCanceled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.TODO())
-	fileChan := make(chan string, 10)
-	fileChan <- "../testdata/input/20190404_131804.jpg"
+	fileChan := make(chan fileGroup, 10)
+	fileChan <- fileGroup{primary: "../testdata/input/20190404_131804.jpg"}
 	close(fileChan)
 	actionChan := make(chan moveAction, 10)
 	var wgGlobal sync.WaitGroup
@@ -179,57 +248,81 @@ func TestGetMoveActionsCanceled(t *testing.T) {
 	assert.Equal(t, 0, actionCount)
 }
 
+func TestGetMoveActionsExtractorFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	fileChan := make(chan fileGroup, 10)
+	fileChan <- fileGroup{primary: "../testdata/input/20190404_131804.jpg"}
+	close(fileChan)
+	actionChan := make(chan moveAction, 10)
+	var wgGlobal sync.WaitGroup
+	wgGlobal.Add(1)
+
+	c := buildDefaultClassifier(t, 2)
+	c.extractorFactory = func() (MetadataExtractor, error) {
+		return nil, fmt.Errorf("error")
+	}
+	c.getMoveActions(ctx, cancel, fileChan, actionChan, &wgGlobal)
+
+	actionCount := 0
+	for range actionChan {
+		actionCount++
+	}
+	assert.Equal(t, 0, actionCount)
+	assert.NotNil(t, ctx.Err())
+}
+
 func TestGetMoveActions(t *testing.T) {
+	files := []string{
+		"../testdata/input/20190404_131804.jpg",
+		"../testdata/input/subFolder/20190404_131805.jpg",
+		"../testdata/input/subFolder/20190404_131806.jpg",
+		"../testdata/input/subFolder/noDate.txt",
+	}
+	expActions := []moveAction{
+		{from: "../testdata/input/20190404_131804.jpg", to: "2019_04"},
+		{from: "../testdata/input/subFolder/20190404_131805.jpg", to: "2019_04"},
+		{from: "../testdata/input/subFolder/20190404_131806.jpg", to: "2019_04"},
+		{from: "../testdata/input/subFolder/noDate.txt", err: errNoDateFount, reason: reasonNoDate},
+	}
 	var tcs = []struct {
-		tcID       string
-		files      []string
-		expActions []moveAction
+		tcID      string
+		batchSize uint
+		workers   uint
 	}{
-		{
-			tcID: "nominal",
-			files: []string{
-				"../testdata/input/20190404_131804.jpg",
-				"../testdata/input/subFolder/20190404_131805.jpg",
-				"../testdata/input/subFolder/20190404_131806.jpg",
-			},
-			expActions: []moveAction{
-				{from: "../testdata/input/20190404_131804.jpg", to: "2019_04"},
-				{from: "../testdata/input/subFolder/20190404_131805.jpg", to: "2019_04"},
-				{from: "../testdata/input/subFolder/20190404_131806.jpg", to: "2019_04"},
-			},
-		},
+		{"singleWorker", 2, 1},
+		{"multipleWorkers", 1, 3},
+		{"moreWorkersThanBatches", 10, 4},
 	}
 
 	for _, tc := range tcs {
 		t.Run(tc.tcID, func(t *testing.T) {
-			func() {
-				ctx, cancel := context.WithCancel(context.TODO())
-				fileChan := make(chan string, 10)
-				actionChan := make(chan moveAction, 10)
-				var wgGlobal sync.WaitGroup
-				wgGlobal.Add(1)
-
-				for _, s := range tc.files {
-					fileChan <- s
-				}
-				close(fileChan)
-
-				c := buildDefaultClassifier(t, 2)
-				c.getMoveActions(ctx, cancel, fileChan, actionChan, &wgGlobal)
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			fileChan := make(chan fileGroup, 10)
+			actionChan := make(chan moveAction, 10)
+			var wgGlobal sync.WaitGroup
+			wgGlobal.Add(1)
 
-				actions := []moveAction{}
-				for ma := range actionChan {
-					actions = append(actions, ma)
-				}
-				assert.Subset(t, actions, tc.expActions)
+			for _, g := range singleGroups(files...) {
+				fileChan <- g
+			}
+			close(fileChan)
 
-			}()
+			c := buildDefaultClassifier(t, tc.batchSize)
+			c.workers = tc.workers
+			c.getMoveActions(ctx, cancel, fileChan, actionChan, &wgGlobal)
 
+			actions := []moveAction{}
+			for ma := range actionChan {
+				actions = append(actions, withoutTemplateData(t, ma))
+			}
+			assert.ElementsMatch(t, expActions, actions)
 		})
 	}
 }
 
 func TestMoveFiles(t *testing.T) {
+	assert.Nil(t, os.RemoveAll("../testdata/tmp/batch/TestMoveFilesNominal"))
 	assert.Nil(t, os.MkdirAll("../testdata/tmp/batch/TestMoveFilesNominal/in", 0777))
 	assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", "../testdata/tmp/batch/TestMoveFilesNominal/in/20190404_131804.jpg"))
 
@@ -241,13 +334,168 @@ func TestMoveFiles(t *testing.T) {
 	wgGlobal.Add(1)
 
 	c := buildDefaultClassifier(t, 2)
-	c.moveFiles(ctx, cancel, "../testdata/tmp/batch/TestMoveFilesNominal/out", moveChan, &wgGlobal)
+	c.moveFiles(ctx, cancel, "", "../testdata/tmp/batch/TestMoveFilesNominal/out", moveChan, &wgGlobal)
 
 	checkExist(t, "../testdata/tmp/batch/TestMoveFilesNominal/in/20190404_131804.jpg", false)
 	checkExist(t, "../testdata/tmp/batch/TestMoveFilesNominal/out/2019_04/20190404_131804.jpg", true)
 }
 
+func TestMoveFilesCollision(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestMoveFilesCollision"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/a/f.jpg", "a")
+	writeFile(t, dir+"/in/b/f.jpg", "b")
+	writeFile(t, dir+"/in/c/f.jpg", "a")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	moveChan := make(chan moveAction, 3)
+	moveChan <- moveAction{from: dir + "/in/a/f.jpg", to: "2019_04"}
+	moveChan <- moveAction{from: dir + "/in/b/f.jpg", to: "2019_04"}
+	moveChan <- moveAction{from: dir + "/in/c/f.jpg", to: "2019_04"}
+	close(moveChan)
+	var wgGlobal sync.WaitGroup
+	wgGlobal.Add(1)
+
+	c := buildDefaultClassifier(t, 2)
+	c.moveFiles(ctx, cancel, "", dir+"/out", moveChan, &wgGlobal)
+
+	checkExist(t, dir+"/in/a/f.jpg", false)
+	checkExist(t, dir+"/in/b/f.jpg", false)
+	checkExist(t, dir+"/in/c/f.jpg", true)
+	checkExist(t, dir+"/out/2019_04/f.jpg", true)
+	checkExist(t, dir+"/out/2019_04/f_1.jpg", true)
+	assert.Nil(t, ctx.Err())
+}
+
+func TestMoveFilesCollisionFail(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestMoveFilesCollisionFail"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/a/f.jpg", "a")
+	writeFile(t, dir+"/in/b/f.jpg", "b")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	moveChan := make(chan moveAction, 2)
+	moveChan <- moveAction{from: dir + "/in/a/f.jpg", to: "2019_04"}
+	moveChan <- moveAction{from: dir + "/in/b/f.jpg", to: "2019_04"}
+	close(moveChan)
+	var wgGlobal sync.WaitGroup
+	wgGlobal.Add(1)
+
+	c := buildDefaultClassifier(t, 2)
+	c.collisionPolicy = CollisionFail
+	c.moveFiles(ctx, cancel, "", dir+"/out", moveChan, &wgGlobal)
+
+	checkExist(t, dir+"/in/a/f.jpg", false)
+	checkExist(t, dir+"/in/b/f.jpg", true)
+	assert.NotNil(t, ctx.Err())
+}
+
+func TestTargetPath(t *testing.T) {
+	var tcs = []struct {
+		tcID              string
+		unknownDateFolder string
+		errorFolder       string
+		ma                moveAction
+		expTarget         string
+		expFound          bool
+	}{
+		{"dated", "", "", moveAction{from: "/in/a/b.jpg", to: "2019_04"}, "/out/2019_04/b.jpg", true},
+		{"noDateLeftInPlace", "", "errors", moveAction{from: "/in/a/b.jpg", err: errNoDateFount, reason: reasonNoDate}, "", false},
+		{"noDate", "unknown", "errors", moveAction{from: "/in/a/b.jpg", err: errNoDateFount, reason: reasonNoDate}, "/out/unknown/a/b.jpg", true},
+		{"noDateAbsolute", "/unknown", "", moveAction{from: "/in/a/b.jpg", err: errNoDateFount, reason: reasonNoDate}, "/unknown/a/b.jpg", true},
+		{"errorLeftInPlace", "unknown", "", moveAction{from: "/in/a/b.jpg", err: fmt.Errorf("error"), reason: reasonExtractionError}, "", false},
+		{"error", "unknown", "errors", moveAction{from: "/in/a/b.jpg", err: fmt.Errorf("error"), reason: reasonInvalidDate}, "/out/errors/a/b.jpg", true},
+		{"outsideInput", "unknown", "", moveAction{from: "/elsewhere/b.jpg", err: errNoDateFount, reason: reasonNoDate}, "/out/unknown/b.jpg", true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			c, err := NewClassifier(OptUnknownDateFolder(tc.unknownDateFolder), OptErrorFolder(tc.errorFolder))
+			assert.Nil(t, err)
+			target, found := c.targetPath("/in", "/out", tc.ma)
+			assert.Equal(t, tc.expFound, found)
+			assert.Equal(t, tc.expTarget, target)
+		})
+	}
+}
+
+func TestClassifyUnknownDateFolder(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestClassifyUnknownDateFolder"
+	assert.Nil(t, os.RemoveAll(dir))
+	assert.Nil(t, os.MkdirAll(dir+"/in/subFolder", 0777))
+	assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", dir+"/in/20190404_131804.jpg"))
+	assert.Nil(t, copy("../testdata/input/subFolder/noDate.txt", dir+"/in/subFolder/noDate.txt"))
+
+	c := buildDefaultClassifier(t, 2)
+	c.unknownDateFolder = "unknown"
+	assert.Nil(t, c.Classify(dir+"/in", dir+"/out"))
+
+	checkExist(t, dir+"/in/subFolder/noDate.txt", false)
+	checkExist(t, dir+"/out/unknown/subFolder/noDate.txt", true)
+	checkExist(t, dir+"/out/2019_04/20190404_131804.jpg", true)
+}
+
+func TestClassifyConcurrently(t *testing.T) {
+	var tcs = []struct {
+		tcID         string
+		dateFields   []DateField
+		outputFormat string
+		expFolder    string
+	}{
+		{"createDateByMonth", []DateField{{Field: "CreateDate", Patterns: []string{"2006:01:02 15:04:05"}}}, "2006_01", "2019_04"},
+		{"createDateByYear", []DateField{{Field: "CreateDate", Patterns: []string{"2006:01:02 15:04:05"}}}, "2006", "2019"},
+		{"fileName", []DateField{{Field: "FileName", Patterns: []string{"20060102_150405.jpg"}}}, "2006-01-02", "2019-04-04"},
+		{"missingField", []DateField{{Field: "Missing", Patterns: []string{"2006:01:02 15:04:05"}}}, "2006_01", ""},
+	}
+
+	t.Run("group", func(t *testing.T) {
+		for _, tc := range tcs {
+			tc := tc
+			t.Run(tc.tcID, func(t *testing.T) {
+				t.Parallel()
+				dir := "../testdata/tmp/batch/TestClassifyConcurrently/" + tc.tcID
+				assert.Nil(t, os.RemoveAll(dir))
+				assert.Nil(t, os.MkdirAll(dir+"/in", 0777))
+				for _, f := range []string{"20190404_131804.jpg", "20190404_131805.jpg", "20190404_131806.jpg"} {
+					assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", dir+"/in/"+f))
+				}
+
+				c, err := NewClassifier(
+					OptBatchSize(1),
+					OptWorkers(2),
+					OptDateFields(tc.dateFields),
+					OptOutputDateFormat(tc.outputFormat),
+					OptMetadataExtractor(fakeExtractorFactory),
+				)
+				assert.Nil(t, err)
+				assert.Nil(t, c.Classify(dir+"/in", dir+"/out"))
+
+				for _, f := range []string{"20190404_131804.jpg", "20190404_131805.jpg", "20190404_131806.jpg"} {
+					checkExist(t, dir+"/in/"+f, tc.expFolder == "")
+					if tc.expFolder != "" {
+						checkExist(t, dir+"/out/"+tc.expFolder+"/"+f, true)
+					}
+				}
+				assert.Equal(t, tc.dateFields, c.dateFields)
+			})
+		}
+	})
+}
+
+func TestOptDateFields(t *testing.T) {
+	fields := []DateField{{Field: "a", Patterns: []string{"2006"}}}
+	c1, err := NewClassifier(OptDateFields(fields))
+	assert.Nil(t, err)
+	c2, err := NewClassifier(OptDateFields([]DateField{{Field: "b", Patterns: []string{"2006"}}}))
+	assert.Nil(t, err)
+	fields[0].Field = "modified"
+
+	assert.Equal(t, []DateField{{Field: "a", Patterns: []string{"2006"}}}, c1.dateFields)
+	assert.Equal(t, []DateField{{Field: "b", Patterns: []string{"2006"}}}, c2.dateFields)
+}
+
 func TestClassify(t *testing.T) {
+	assert.Nil(t, os.RemoveAll("../testdata/tmp/batch/TestClassify"))
 	assert.Nil(t, os.MkdirAll("../testdata/tmp/batch/TestClassify/in/subFolder", 0777))
 	assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", "../testdata/tmp/batch/TestClassify/in/subFolder/20190404_131805.jpg"))
 	assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", "../testdata/tmp/batch/TestClassify/in/subFolder/20190404_131806.jpg"))
@@ -266,49 +514,73 @@ func TestClassify(t *testing.T) {
 	checkExist(t, "../testdata/tmp/batch/TestClassify/out/2019_04/20190404_131806.jpg", true)
 }
 
-func TestGuessDateNominal(t *testing.T) {
-	fields := map[string]interface{}{
-		"a":          "b",
-		"CreateDate": "2018:01:02 03:04:05",
-	}
-	fm := exiftool.FileMetadata{File: "a", Fields: fields}
+func TestPlan(t *testing.T) {
 	c := buildDefaultClassifier(t, 2)
-	got, err := c.guessDate(fm)
+	p, err := c.Plan("../testdata/input", "/out")
 	assert.Nil(t, err)
-	assert.Equal(t, 2018, got.Year())
-	assert.Equal(t, time.January, got.Month())
-	assert.Equal(t, 2, got.Day())
-	assert.Equal(t, 3, got.Hour())
-	assert.Equal(t, 4, got.Minute())
-	assert.Equal(t, 5, got.Second())
+
+	assert.Equal(t, []PlannedMove{
+		{From: "../testdata/input/20190404_131804.jpg", To: "/out/2019_04/20190404_131804.jpg"},
+		{From: "../testdata/input/subFolder/20190404_131805.jpg", To: "/out/2019_04/20190404_131805.jpg"},
+		{From: "../testdata/input/subFolder/20190404_131806.jpg", To: "/out/2019_04/20190404_131806.jpg"},
+	}, p.Moves)
+	assert.Equal(t, []UnclassifiedFile{
+		{File: "../testdata/input/subFolder/noDate.txt", Reason: errNoDateFount.Error()},
+	}, p.Unclassified)
+	checkExist(t, "../testdata/input/20190404_131804.jpg", true)
+	checkExist(t, "../testdata/input/subFolder/20190404_131805.jpg", true)
 }
 
-func TestGuessDateWithoutDateField(t *testing.T) {
-	fields := map[string]interface{}{
-		"a": "b",
-	}
-	fm := exiftool.FileMetadata{File: "a", Fields: fields}
+func TestPlanCollision(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestPlanCollision"
+	assert.Nil(t, os.RemoveAll(dir))
+	assert.Nil(t, os.MkdirAll(dir+"/in/a", 0777))
+	assert.Nil(t, os.MkdirAll(dir+"/in/b", 0777))
+	assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", dir+"/in/a/20190404_131804.jpg"))
+	assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", dir+"/in/b/20190404_131804.jpg"))
+	writeFile(t, dir+"/out/2019_04/20190404_131804.jpg", "other")
+
 	c := buildDefaultClassifier(t, 2)
-	_, err := c.guessDate(fm)
-	assert.Equal(t, errNoDateFount, err)
+	p, err := c.Plan(dir+"/in", dir+"/out")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlannedMove{
+		{From: dir + "/in/a/20190404_131804.jpg", To: dir + "/out/2019_04/20190404_131804_1.jpg"},
+	}, p.Moves)
+	assert.Equal(t, []UnclassifiedFile{
+		{File: dir + "/in/b/20190404_131804.jpg", Reason: errDuplicate.Error()},
+	}, p.Unclassified)
 }
 
-func TestGuessDateUnparsableDate(t *testing.T) {
-	fields := map[string]interface{}{
-		"a":          "b",
-		"CreateDate": "unparsableDate",
-	}
-	fm := exiftool.FileMetadata{File: "a", Fields: fields}
+func TestPlanCanceled(t *testing.T) {
 	c := buildDefaultClassifier(t, 2)
-	_, err := c.guessDate(fm)
+	_, err := c.Plan("../nonExistingFolder", "/out")
 	assert.NotNil(t, err)
-	assert.NotEqual(t, errNoDateFount, err)
+}
+
+// withoutTemplateData checks and removes the data provided to the destination template
+func withoutTemplateData(t *testing.T, ma moveAction) moveAction {
+	if ma.err == nil {
+		assert.Equal(t, time.Date(2019, 4, 4, 13, 18, 3, 0, time.UTC), ma.date)
+		assert.Equal(t, "SM-G930F", ma.fields["Model"])
+	}
+	ma.date = time.Time{}
+	ma.fields = nil
+	return ma
+}
+
+func singleGroups(files ...string) []fileGroup {
+	groups := make([]fileGroup, len(files))
+	for i, f := range files {
+		groups[i] = fileGroup{primary: f}
+	}
+	return groups
 }
 
 func buildDefaultClassifier(t *testing.T, batchSize uint) *Classifier {
 	c, err := NewClassifier(
 		OptBatchSize(batchSize),
-		OptDateFields(map[string]string{"CreateDate": "2006:01:02 15:04:05"}),
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"2006:01:02 15:04:05"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
 	)
 	assert.Nil(t, err)
 	return c
@@ -0,0 +1,156 @@
+package classifier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Collision policies, applied when a file with the same name already exists in the destination folder
+const (
+	// CollisionSkip leaves the source file untouched
+	CollisionSkip = "skip"
+	// CollisionOverwrite replaces the existing file
+	CollisionOverwrite = "overwrite"
+	// CollisionRename adds a numeric suffix to the file name (file_1.jpg, file_2.jpg, ...)
+	CollisionRename = "rename"
+	// CollisionRenameWithHash adds a suffix based on the content hash to the file name (file_3f2a9c1e.jpg)
+	CollisionRenameWithHash = "rename-with-hash"
+	// CollisionFail stops the classification
+	CollisionFail = "fail"
+)
+
+var collisionPolicies = map[string]bool{
+	CollisionSkip:           true,
+	CollisionOverwrite:      true,
+	CollisionRename:         true,
+	CollisionRenameWithHash: true,
+	CollisionFail:           true,
+}
+
+var errDuplicate = fmt.Errorf("identical file already exists in destination")
+var errCollisionSkipped = fmt.Errorf("destination already exists, skipped")
+var errCollision = fmt.Errorf("destination already exists")
+
+// occupantFunc returns the path of the file whose content is (or will be) stored at the provided
+// destination, and false if the destination is free
+type occupantFunc func(to string) (string, bool)
+
+func existingOccupant(to string) (string, bool) {
+	if _, err := os.Lstat(to); err != nil {
+		return "", false
+	}
+	return to, true
+}
+
+// resolveCollision returns the path where the file from has to be stored, according to the
+// collision policy. errDuplicate is returned if an identical file already exists, errCollisionSkipped
+// if the file must be left untouched and errCollision if the classification must stop.
+func (cl *Classifier) resolveCollision(from string, to string, occupant occupantFunc) (string, error) {
+	current, found := occupant(to)
+	if !found {
+		return to, nil
+	}
+	if same, err := sameContent(from, current); err != nil {
+		return "", err
+	} else if same {
+		return "", errDuplicate
+	}
+
+	switch cl.collisionPolicy {
+	case CollisionSkip:
+		return "", errCollisionSkipped
+	case CollisionOverwrite:
+		return to, nil
+	case CollisionRenameWithHash:
+		h, err := fileHash(from)
+		if err != nil {
+			return "", err
+		}
+		hashed := suffixed(to, fmt.Sprintf("%x", h[:4]))
+		if current, found := occupant(hashed); !found {
+			return hashed, nil
+		} else if same, err := sameContent(from, current); err != nil {
+			return "", err
+		} else if same {
+			return "", errDuplicate
+		}
+		return renameWithCounter(from, hashed, occupant)
+	case CollisionRename:
+		return renameWithCounter(from, to, occupant)
+	default:
+		return "", errCollision
+	}
+}
+
+func renameWithCounter(from string, to string, occupant occupantFunc) (string, error) {
+	return firstFree(from, occupant, func(i int) string {
+		return suffixed(to, fmt.Sprintf("%v", i))
+	})
+}
+
+// firstFree returns the first free destination among the candidates built with increasing counters
+// (starting at 1) by the build function. errDuplicate is returned if an identical file is found first.
+func firstFree(from string, occupant occupantFunc, build func(int) string) (string, error) {
+	for i := 1; ; i++ {
+		candidate := build(i)
+		current, found := occupant(candidate)
+		if !found {
+			return candidate, nil
+		}
+		if same, err := sameContent(from, current); err != nil {
+			return "", err
+		} else if same {
+			return "", errDuplicate
+		}
+	}
+}
+
+// suffixed adds "_suffix" between the name and the extension of path
+func suffixed(path string, suffix string) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%v_%v%v", strings.TrimSuffix(path, ext), suffix, ext)
+}
+
+func sameContent(a string, b string) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	ib, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if ia.Size() != ib.Size() {
+		return false, nil
+	}
+	ha, err := fileHash(a)
+	if err != nil {
+		return false, err
+	}
+	hb, err := fileHash(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ha, hb), nil
+}
+
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("error while hashing %v: %v", path, err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,68 @@
+package classifier
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0777))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0666))
+}
+
+func TestResolveCollision(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestResolveCollision"
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte("new")))[:8]
+	var tcs = []struct {
+		tcID     string
+		policy   string
+		existing map[string]string
+		expTo    string
+		expErr   error
+	}{
+		{"noCollision", CollisionFail, map[string]string{}, "a.jpg", nil},
+		{"duplicate", CollisionFail, map[string]string{"a.jpg": "new"}, "", errDuplicate},
+		{"skip", CollisionSkip, map[string]string{"a.jpg": "old"}, "", errCollisionSkipped},
+		{"overwrite", CollisionOverwrite, map[string]string{"a.jpg": "old"}, "a.jpg", nil},
+		{"rename", CollisionRename, map[string]string{"a.jpg": "old"}, "a_1.jpg", nil},
+		{"renameTwice", CollisionRename, map[string]string{"a.jpg": "old", "a_1.jpg": "older"}, "a_2.jpg", nil},
+		{"renameDuplicate", CollisionRename, map[string]string{"a.jpg": "old", "a_1.jpg": "new"}, "", errDuplicate},
+		{"renameWithHash", CollisionRenameWithHash, map[string]string{"a.jpg": "old"}, "a_" + hash + ".jpg", nil},
+		{"renameWithHashDuplicate", CollisionRenameWithHash, map[string]string{"a.jpg": "old", "a_" + hash + ".jpg": "new"}, "", errDuplicate},
+		{"fail", CollisionFail, map[string]string{"a.jpg": "old"}, "", errCollision},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Nil(t, os.RemoveAll(dir))
+			writeFile(t, filepath.Join(dir, "in", "a.jpg"), "new")
+			for f, content := range tc.existing {
+				writeFile(t, filepath.Join(dir, "out", f), content)
+			}
+
+			c, err := NewClassifier(OptCollisionPolicy(tc.policy))
+			assert.Nil(t, err)
+			to, err := c.resolveCollision(filepath.Join(dir, "in", "a.jpg"), filepath.Join(dir, "out", "a.jpg"), existingOccupant)
+			assert.Equal(t, tc.expErr, err)
+			if tc.expErr == nil {
+				assert.Equal(t, filepath.Join(dir, "out", tc.expTo), to)
+			}
+		})
+	}
+}
+
+func TestOptCollisionPolicy(t *testing.T) {
+	_, err := NewClassifier(OptCollisionPolicy("unknown"))
+	assert.NotNil(t, err)
+}
+
+func TestSuffixed(t *testing.T) {
+	assert.Equal(t, "/a/b_1.jpg", suffixed("/a/b.jpg", "1"))
+	assert.Equal(t, "/a/b_1", suffixed("/a/b", "1"))
+}
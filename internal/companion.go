@@ -0,0 +1,149 @@
+package classifier
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// CompanionRule groups the files of a folder sharing the same base name : the file whose extension
+// comes first in Primaries is the primary file, the files whose extension is in Companions follow it
+// wherever it is dispatched. Companions can also be named after the full primary file name
+// (IMG_1234.CR2.xmp). Extensions are specified without dot and are case insensitive.
+type CompanionRule struct {
+	Primaries  []string
+	Companions []string
+}
+
+// DefaultCompanionRules group RAW files with their JPEG and sidecar files, Apple Live Photos
+// (HEIC+MOV) and Apple edits (AAE)
+var DefaultCompanionRules = []CompanionRule{
+	{
+		Primaries:  []string{"cr2", "cr3", "nef", "arw", "orf", "rw2", "raf", "dng", "heic", "jpg", "jpeg"},
+		Companions: []string{"jpg", "jpeg", "mov", "xmp", "aae", "thm"},
+	},
+	{
+		Primaries:  []string{"mp4", "mov"},
+		Companions: []string{"xmp", "thm"},
+	},
+}
+
+// fileGroup is a file and its companions, rel being the path of the file relative to the input folder
+type fileGroup struct {
+	primary    string
+	companions []string
+	rel        string
+}
+
+func (r CompanionRule) check() error {
+	if len(r.Primaries) == 0 {
+		return fmt.Errorf("no primary extension specified in companion rule")
+	}
+	if len(r.Companions) == 0 {
+		return fmt.Errorf("no companion extension specified in companion rule")
+	}
+	return nil
+}
+
+// extIndex returns the position of the extension of name in exts, -1 if not found
+func extIndex(name string, exts []string) int {
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	for i, e := range exts {
+		if strings.EqualFold(ext, strings.TrimPrefix(e, ".")) {
+			return i
+		}
+	}
+	return -1
+}
+
+func stem(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// groupFiles groups the files of a single folder according to the companion rules, the rules being
+// applied in order. Every file belongs to exactly one group.
+func (cl *Classifier) groupFiles(files []string) []fileGroup {
+	remaining := files
+	groups := []fileGroup{}
+	for _, r := range cl.companionRules {
+		var grouped []fileGroup
+		grouped, remaining = r.group(remaining)
+		groups = append(groups, grouped...)
+	}
+	for _, f := range remaining {
+		groups = append(groups, fileGroup{primary: f})
+	}
+	return groups
+}
+
+// group returns the groups built by the rule and the files that are not part of any of them
+func (r CompanionRule) group(files []string) ([]fileGroup, []string) {
+	primaries := map[string]string{}
+	for _, f := range files {
+		i := extIndex(f, r.Primaries)
+		if i < 0 {
+			continue
+		}
+		s := stem(f)
+		if current, found := primaries[s]; !found || i < extIndex(current, r.Primaries) {
+			primaries[s] = f
+		}
+	}
+
+	companions := map[string][]string{}
+	isCompanion := map[string]bool{}
+	for _, f := range files {
+		if extIndex(f, r.Companions) < 0 {
+			continue
+		}
+		candidates := []string{stem(f)}
+		if extIndex(stem(f), r.Primaries) >= 0 {
+			candidates = append(candidates, stem(stem(f)))
+		}
+		for _, c := range candidates {
+			if p, found := primaries[c]; found && p != f {
+				companions[p] = append(companions[p], f)
+				isCompanion[f] = true
+				break
+			}
+		}
+	}
+
+	groups := []fileGroup{}
+	others := []string{}
+	for _, f := range files {
+		switch {
+		case isCompanion[f]:
+		case len(companions[f]) > 0:
+			groups = append(groups, fileGroup{primary: f, companions: companions[f]})
+		default:
+			others = append(others, f)
+		}
+	}
+	return groups, others
+}
+
+// companionTarget computes where a companion has to be dispatched : next to the primary file, with
+// the same base name as the primary one (which can have been renamed)
+func companionTarget(primaryFrom string, primaryTo string, companion string) string {
+	name := filepath.Base(companion)
+	suffix := strings.TrimPrefix(name, stem(filepath.Base(primaryFrom)))
+	return filepath.Join(filepath.Dir(primaryTo), stem(filepath.Base(primaryTo))+suffix)
+}
+
+// groupOccupant extends an occupant to a group of files : a destination of the primary file is
+// occupied when it is or when one of the destinations its companions would be given is, so that a
+// single free name is chosen for the whole group
+func groupOccupant(occupant occupantFunc, primaryFrom string, companions []string) occupantFunc {
+	return func(to string) (string, bool) {
+		if current, found := occupant(to); found {
+			return current, true
+		}
+		for _, c := range companions {
+			if current, found := occupant(companionTarget(primaryFrom, to, c)); found {
+				return current, true
+			}
+		}
+		return "", false
+	}
+}
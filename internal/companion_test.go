@@ -0,0 +1,193 @@
+package classifier
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupFiles(t *testing.T) {
+	var tcs = []struct {
+		tcID      string
+		rules     []CompanionRule
+		files     []string
+		expGroups []fileGroup
+	}{
+		{
+			tcID:      "noRule",
+			rules:     nil,
+			files:     []string{"/a/IMG_1.CR2", "/a/IMG_1.xmp"},
+			expGroups: []fileGroup{{primary: "/a/IMG_1.CR2"}, {primary: "/a/IMG_1.xmp"}},
+		}, {
+			tcID:  "rawJpegSidecars",
+			rules: DefaultCompanionRules,
+			files: []string{"/a/IMG_1.CR2", "/a/IMG_1.CR2.xmp", "/a/IMG_1.JPG", "/a/IMG_1.THM", "/a/IMG_2.JPG"},
+			expGroups: []fileGroup{
+				{primary: "/a/IMG_1.CR2", companions: []string{"/a/IMG_1.CR2.xmp", "/a/IMG_1.JPG", "/a/IMG_1.THM"}},
+				{primary: "/a/IMG_2.JPG"},
+			},
+		}, {
+			tcID:  "livePhoto",
+			rules: DefaultCompanionRules,
+			files: []string{"/a/IMG_3.AAE", "/a/IMG_3.HEIC", "/a/IMG_3.MOV", "/a/IMG_4.MOV"},
+			expGroups: []fileGroup{
+				{primary: "/a/IMG_3.HEIC", companions: []string{"/a/IMG_3.AAE", "/a/IMG_3.MOV"}},
+				{primary: "/a/IMG_4.MOV"},
+			},
+		}, {
+			tcID:  "secondRule",
+			rules: DefaultCompanionRules,
+			files: []string{"/a/VID_1.mp4", "/a/VID_1.thm", "/a/VID_2.MOV", "/a/VID_2.xmp"},
+			expGroups: []fileGroup{
+				{primary: "/a/VID_1.mp4", companions: []string{"/a/VID_1.thm"}},
+				{primary: "/a/VID_2.MOV", companions: []string{"/a/VID_2.xmp"}},
+			},
+		}, {
+			tcID:      "orphanCompanion",
+			rules:     DefaultCompanionRules,
+			files:     []string{"/a/IMG_5.xmp", "/a/IMG_6.txt"},
+			expGroups: []fileGroup{{primary: "/a/IMG_5.xmp"}, {primary: "/a/IMG_6.txt"}},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			c, err := NewClassifier(OptCompanionRules(tc.rules))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expGroups, c.groupFiles(tc.files))
+		})
+	}
+}
+
+func TestOptCompanionRules(t *testing.T) {
+	_, err := NewClassifier(OptCompanionRules([]CompanionRule{{Primaries: []string{"cr2"}}}))
+	assert.NotNil(t, err)
+	_, err = NewClassifier(OptCompanionRules([]CompanionRule{{Companions: []string{"xmp"}}}))
+	assert.NotNil(t, err)
+}
+
+func TestCompanionTarget(t *testing.T) {
+	assert.Equal(t, "/out/2019_04/IMG_1.xmp", companionTarget("/in/IMG_1.CR2", "/out/2019_04/IMG_1.CR2", "/in/IMG_1.xmp"))
+	assert.Equal(t, "/out/2019_04/IMG_1_1.xmp", companionTarget("/in/IMG_1.CR2", "/out/2019_04/IMG_1_1.CR2", "/in/IMG_1.xmp"))
+	assert.Equal(t, "/out/2019_04/IMG_1_1.CR2.xmp", companionTarget("/in/IMG_1.CR2", "/out/2019_04/IMG_1_1.CR2", "/in/IMG_1.CR2.xmp"))
+}
+
+func TestMoveFilesCompanions(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestMoveFilesCompanions"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/IMG_1.CR2", "raw")
+	writeFile(t, dir+"/in/IMG_1.xmp", "xmp")
+	writeFile(t, dir+"/in/IMG_1.JPG", "jpg")
+	writeFile(t, dir+"/out/2019_04/IMG_1.CR2", "other raw")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	moveChan := make(chan moveAction, 1)
+	moveChan <- moveAction{from: dir + "/in/IMG_1.CR2", to: "2019_04", companions: []string{dir + "/in/IMG_1.JPG", dir + "/in/IMG_1.xmp"}}
+	close(moveChan)
+	var wgGlobal sync.WaitGroup
+	wgGlobal.Add(1)
+
+	c := buildDefaultClassifier(t, 2)
+	c.moveFiles(ctx, cancel, dir+"/in", dir+"/out", moveChan, &wgGlobal)
+
+	checkExist(t, dir+"/in/IMG_1.CR2", false)
+	checkExist(t, dir+"/in/IMG_1.xmp", false)
+	checkExist(t, dir+"/in/IMG_1.JPG", false)
+	checkExist(t, dir+"/out/2019_04/IMG_1_1.CR2", true)
+	checkExist(t, dir+"/out/2019_04/IMG_1_1.xmp", true)
+	checkExist(t, dir+"/out/2019_04/IMG_1_1.JPG", true)
+}
+
+func TestMoveFilesCompanionCollision(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestMoveFilesCompanionCollision"
+	var tcs = []struct {
+		tcID            string
+		collisionPolicy string
+		expMoved        bool
+		expCanceled     bool
+	}{
+		{"rename", CollisionRename, true, false},
+		{"fail", CollisionFail, false, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Nil(t, os.RemoveAll(dir))
+			writeFile(t, dir+"/in/IMG_1.jpg", "jpg")
+			writeFile(t, dir+"/in/IMG_1.xmp", "xmp")
+			writeFile(t, dir+"/out/2019_04/IMG_1.xmp", "other xmp")
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			moveChan := make(chan moveAction, 1)
+			moveChan <- moveAction{from: dir + "/in/IMG_1.jpg", to: "2019_04", companions: []string{dir + "/in/IMG_1.xmp"}}
+			close(moveChan)
+			var wgGlobal sync.WaitGroup
+			wgGlobal.Add(1)
+
+			c := buildDefaultClassifier(t, 2)
+			c.collisionPolicy = tc.collisionPolicy
+			c.moveFiles(ctx, cancel, dir+"/in", dir+"/out", moveChan, &wgGlobal)
+
+			checkExist(t, dir+"/in/IMG_1.jpg", !tc.expMoved)
+			checkExist(t, dir+"/in/IMG_1.xmp", !tc.expMoved)
+			checkExist(t, dir+"/out/2019_04/IMG_1.jpg", false)
+			checkExist(t, dir+"/out/2019_04/IMG_1_1.jpg", tc.expMoved)
+			checkExist(t, dir+"/out/2019_04/IMG_1_1.xmp", tc.expMoved)
+			assert.Equal(t, tc.expCanceled, ctx.Err() != nil)
+		})
+	}
+}
+
+func TestPlanCompanionCollision(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestPlanCompanionCollision"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/20190404_131804.jpg", "jpg")
+	writeFile(t, dir+"/in/20190404_131804.xmp", "xmp")
+	writeFile(t, dir+"/out/2019_04/20190404_131804.xmp", "other xmp")
+
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptCompanionRules(DefaultCompanionRules),
+		OptCollisionPolicy(CollisionRename),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan(dir+"/in", dir+"/out")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []PlannedMove{
+		{From: dir + "/in/20190404_131804.jpg", To: dir + "/out/2019_04/20190404_131804_1.jpg"},
+		{From: dir + "/in/20190404_131804.xmp", To: dir + "/out/2019_04/20190404_131804_1.xmp", CompanionOf: dir + "/in/20190404_131804.jpg"},
+	}, p.Moves)
+	assert.Empty(t, p.Unclassified)
+}
+
+func TestPlanCompanions(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestPlanCompanions"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/20190404_131804.jpg", "jpg")
+	writeFile(t, dir+"/in/20190404_131804.xmp", "xmp")
+	writeFile(t, dir+"/in/noDate.heic", "heic")
+	writeFile(t, dir+"/in/noDate.mov", "mov")
+
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptCompanionRules(DefaultCompanionRules),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan(dir+"/in", "/out")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []PlannedMove{
+		{From: dir + "/in/20190404_131804.jpg", To: "/out/2019_04/20190404_131804.jpg"},
+		{From: dir + "/in/20190404_131804.xmp", To: "/out/2019_04/20190404_131804.xmp", CompanionOf: dir + "/in/20190404_131804.jpg"},
+	}, p.Moves)
+	assert.Equal(t, []UnclassifiedFile{
+		{File: dir + "/in/noDate.heic", Reason: errNoDateFount.Error()},
+		{File: dir + "/in/noDate.mov", Reason: "companion of " + dir + "/in/noDate.heic"},
+	}, p.Unclassified)
+}
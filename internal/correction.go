@@ -0,0 +1,100 @@
+package classifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ClockCorrection fixes the dates of the files shot by a camera whose clock was wrong. The files
+// whose metadata hold all the Match values and whose date is in [From, To[ (zero values meaning
+// unbounded) are shifted by Years, Months, Days and Shift.
+type ClockCorrection struct {
+	// Name describes the correction in the reports, the shift is used if empty
+	Name   string
+	Match  map[string]string
+	From   time.Time
+	To     time.Time
+	Years  int
+	Months int
+	Days   int
+	Shift  time.Duration
+}
+
+func (cc ClockCorrection) check() error {
+	if cc.Years == 0 && cc.Months == 0 && cc.Days == 0 && cc.Shift == 0 {
+		return fmt.Errorf("no shift specified in clock correction %v", cc.Name)
+	}
+	if !cc.From.IsZero() && !cc.To.IsZero() && !cc.From.Before(cc.To) {
+		return fmt.Errorf("empty date range in clock correction %v", cc.description())
+	}
+	return nil
+}
+
+// matches checks if the correction applies to a file and its uncorrected date
+func (cc ClockCorrection) matches(fm FileMetadata, t time.Time) bool {
+	for tag, expected := range cc.Match {
+		val, found := fm.Fields[tag]
+		if !found || strings.TrimSpace(fmt.Sprintf("%v", val)) != expected {
+			return false
+		}
+	}
+	if !cc.From.IsZero() && t.Before(cc.From) {
+		return false
+	}
+	if !cc.To.IsZero() && !t.Before(cc.To) {
+		return false
+	}
+	return true
+}
+
+func (cc ClockCorrection) apply(t time.Time) time.Time {
+	return t.AddDate(cc.Years, cc.Months, cc.Days).Add(cc.Shift)
+}
+
+// description is the name of the correction or its shift (+1y -2mo +3d +1h0m0s)
+func (cc ClockCorrection) description() string {
+	if cc.Name != "" {
+		return cc.Name
+	}
+	parts := []string{}
+	for _, p := range []struct {
+		value int
+		unit  string
+	}{{cc.Years, "y"}, {cc.Months, "mo"}, {cc.Days, "d"}} {
+		if p.value != 0 {
+			parts = append(parts, fmt.Sprintf("%+d%v", p.value, p.unit))
+		}
+	}
+	if cc.Shift > 0 {
+		parts = append(parts, "+"+cc.Shift.String())
+	} else if cc.Shift < 0 {
+		parts = append(parts, cc.Shift.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// OptClockCorrections specifies the corrections applied to the dates of the files shot by cameras whose
+// clock was wrong. The first matching correction is applied.
+func OptClockCorrections(corrections []ClockCorrection) func(*Classifier) error {
+	return func(c *Classifier) error {
+		for _, cc := range corrections {
+			if err := cc.check(); err != nil {
+				return err
+			}
+		}
+		c.clockCorrections = append([]ClockCorrection{}, corrections...)
+		return nil
+	}
+}
+
+// correctDate applies the first matching clock correction to the date. It returns the description of
+// the applied correction, empty if none.
+func (cl *Classifier) correctDate(fm FileMetadata, t time.Time) (time.Time, string) {
+	for _, cc := range cl.clockCorrections {
+		if cc.matches(fm, t) {
+			return cc.apply(t), cc.description()
+		}
+	}
+	return t, ""
+}
@@ -0,0 +1,75 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockCorrectionMatches(t *testing.T) {
+	fm := FileMetadata{Fields: map[string]interface{}{"Model": "EOS 5D", "SerialNumber": 1234}}
+	d := time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC)
+	var tcs = []struct {
+		tcID     string
+		cc       ClockCorrection
+		expMatch bool
+	}{
+		{"noCriteria", ClockCorrection{}, true},
+		{"model", ClockCorrection{Match: map[string]string{"Model": "EOS 5D"}}, true},
+		{"modelAndSerial", ClockCorrection{Match: map[string]string{"Model": "EOS 5D", "SerialNumber": "1234"}}, true},
+		{"otherSerial", ClockCorrection{Match: map[string]string{"Model": "EOS 5D", "SerialNumber": "4321"}}, false},
+		{"missingTag", ClockCorrection{Match: map[string]string{"Make": "Canon"}}, false},
+		{"inRange", ClockCorrection{From: d.AddDate(0, -1, 0), To: d.AddDate(0, 1, 0)}, true},
+		{"fromIncluded", ClockCorrection{From: d}, true},
+		{"toExcluded", ClockCorrection{To: d}, false},
+		{"beforeRange", ClockCorrection{From: d.Add(time.Second)}, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expMatch, tc.cc.matches(fm, d))
+		})
+	}
+}
+
+func TestClockCorrectionDescription(t *testing.T) {
+	assert.Equal(t, "DST", ClockCorrection{Name: "DST", Shift: time.Hour}.description())
+	assert.Equal(t, "+1h0m0s", ClockCorrection{Shift: time.Hour}.description())
+	assert.Equal(t, "-1y +2mo -30m0s", ClockCorrection{Years: -1, Months: 2, Shift: -30 * time.Minute}.description())
+	assert.Equal(t, "+3d", ClockCorrection{Days: 3}.description())
+}
+
+func TestClockCorrectionApply(t *testing.T) {
+	d := time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC)
+	assert.Equal(t, time.Date(2018, 4, 5, 12, 18, 4, 0, time.UTC), ClockCorrection{Years: -1, Days: 1, Shift: -time.Hour}.apply(d))
+}
+
+func TestOptClockCorrections(t *testing.T) {
+	d := time.Date(2019, 4, 4, 0, 0, 0, 0, time.UTC)
+	_, err := NewClassifier(OptClockCorrections([]ClockCorrection{{Match: map[string]string{"Model": "EOS 5D"}}}))
+	assert.NotNil(t, err)
+	_, err = NewClassifier(OptClockCorrections([]ClockCorrection{{From: d, To: d, Shift: time.Hour}}))
+	assert.NotNil(t, err)
+	_, err = NewClassifier(OptClockCorrections([]ClockCorrection{{From: d, To: d.Add(time.Hour), Shift: time.Hour}}))
+	assert.Nil(t, err)
+}
+
+func TestPlanClockCorrection(t *testing.T) {
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptClockCorrections([]ClockCorrection{
+			{Name: "other camera", Match: map[string]string{"Model": "EOS 5D"}, Years: 2},
+			{Match: map[string]string{"Model": "SM-G930F"}, Years: -1},
+			{Name: "never used", Shift: time.Hour},
+		}),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan("../testdata/input", "/out")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlannedMove{
+		{From: "../testdata/input/20190404_131804.jpg", To: "/out/2018_04/20190404_131804.jpg", Correction: "-1y"},
+		{From: "../testdata/input/subFolder/20190404_131805.jpg", To: "/out/2018_04/20190404_131805.jpg", Correction: "-1y"},
+		{From: "../testdata/input/subFolder/20190404_131806.jpg", To: "/out/2018_04/20190404_131806.jpg", Correction: "-1y"},
+	}, p.Moves)
+}
@@ -0,0 +1,268 @@
+package classifier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var errNoDateFount = fmt.Errorf("No data found")
+
+// DatePresets are the named date patterns that can be used instead of golang layouts
+var DatePresets = map[string]string{
+	"exif":        "2006:01:02 15:04:05",
+	"exif-tz":     "2006:01:02 15:04:05Z07:00",
+	"exif-subsec": "2006:01:02 15:04:05.999999999",
+	"rfc3339":     time.RFC3339Nano,
+}
+
+// Date sources
+const (
+	// SourceMetadata reads the date from a metadata field (default)
+	SourceMetadata = "metadata"
+	// SourceFileName reads the date from the file name
+	SourceFileName = "filename"
+	// SourceFileSystem reads the date from a filesystem timestamp, the resulting dates being low confidence ones
+	SourceFileSystem = "filesystem"
+	// SourceXMP reads the date from the XMP sidecar file (IMG_1234.xmp or IMG_1234.CR2.xmp)
+	SourceXMP = "xmp"
+	// SourceTakeout reads the date from the Google Takeout JSON sidecar file (IMG_1234.jpg.json)
+	SourceTakeout = "takeout"
+)
+
+// DateField is a source that can hold the classifying date
+type DateField struct {
+	// Source is the date source (SourceMetadata if empty)
+	Source string
+	// Field is the metadata tag key, the XMP property (prefix:name, DefaultXMPFields if empty) or the
+	// Google Takeout JSON key (DefaultTakeoutField if empty)
+	Field string
+	// Patterns are the date layouts tried in order, either golang layouts (https://golang.org/pkg/time/#Time.Format)
+	// or DatePresets names (DefaultXMPPatterns if empty for SourceXMP)
+	Patterns []string
+	// Regexps are the regular expressions tried in order to extract the date from the file name (SourceFileName).
+	// They must define the year, month and day named groups and can define the hour, minute and second ones.
+	// If empty, DefaultFileNameRegexps are used.
+	Regexps []string
+	// Timestamp is the filesystem timestamp kind used by SourceFileSystem (TimestampModification if empty)
+	Timestamp string
+	// Timezone is the timezone of the dates without offset : TimezoneUTC (default), TimezoneLocal,
+	// TimezoneEmbedded or an IANA timezone name (Europe/Paris)
+	Timezone string
+	// OffsetField is the metadata tag holding the offset of the dates without offset (OffsetTimeOriginal)
+	OffsetField string
+	// SubSecField is the metadata tag holding the fraction of second of the dates without one (SubSecTimeOriginal)
+	SubSecField string
+	// SkipZero ignores the field when it holds a zero date (0000:00:00 00:00:00)
+	SkipZero bool
+
+	regexps  []*regexp.Regexp
+	location *time.Location
+}
+
+// zeroDateError is returned when a field holds a zero date
+type zeroDateError struct {
+	value string
+}
+
+func (e zeroDateError) Error() string {
+	return fmt.Sprintf("zero date %v", e.value)
+}
+
+// init checks and prepares the date field
+func (df *DateField) init() error {
+	if err := df.initTimezone(); err != nil {
+		return err
+	}
+	switch df.Source {
+	case "", SourceMetadata:
+		if len(df.Patterns) == 0 {
+			return fmt.Errorf("no pattern specified for date field %v", df.Field)
+		}
+	case SourceFileName:
+		return df.compileRegexps()
+	case SourceFileSystem:
+		return df.checkTimestamp()
+	case SourceXMP:
+		df.initXMP()
+	case SourceTakeout:
+	default:
+		return fmt.Errorf("unknown date source %v", df.Source)
+	}
+	return nil
+}
+
+// name is the name of the date field in logs and diagnostics
+func (df DateField) name() string {
+	switch df.Source {
+	case SourceXMP, SourceTakeout:
+		if df.Field != "" {
+			return fmt.Sprintf("%v %v", df.Source, df.Field)
+		}
+		return df.Source
+	case SourceFileSystem:
+		return fmt.Sprintf("%v %v", df.Source, df.Timestamp)
+	case SourceFileName:
+		return df.Source
+	}
+	return df.Field
+}
+
+// date extracts the date from the source of the field. It returns false if the source doesn't hold any date.
+func (df DateField) date(fm FileMetadata) (time.Time, bool, error) {
+	switch df.Source {
+	case SourceFileName:
+		return df.fileNameDate(fm.File)
+	case SourceFileSystem:
+		return df.fileSystemDate(fm.File)
+	case SourceXMP:
+		return df.xmpDate(fm.File)
+	case SourceTakeout:
+		return df.takeoutDate(fm.File)
+	default:
+		return df.metadataDate(fm)
+	}
+}
+
+// lowConfidence checks if the dates provided by the field are only approximations of the capture date
+func (df DateField) lowConfidence() bool {
+	return df.Source == SourceFileSystem
+}
+
+func (df DateField) metadataDate(fm FileMetadata) (time.Time, bool, error) {
+	val, found := fm.Fields[df.Field]
+	if !found {
+		return time.Time{}, false, nil
+	}
+	s := fmt.Sprintf("%v", val)
+	if isZeroDate(s) {
+		return time.Time{}, true, zeroDateError{value: s}
+	}
+	offset := ""
+	if v, found := fm.Fields[df.OffsetField]; found && df.OffsetField != "" {
+		offset = fmt.Sprintf("%v", v)
+	}
+	t, err := df.parseWithOffset(s, offset)
+	if err != nil {
+		return t, true, err
+	}
+	if v, found := fm.Fields[df.SubSecField]; found && df.SubSecField != "" && t.Nanosecond() == 0 {
+		if ns, err := subSeconds(fmt.Sprintf("%v", v)); err != nil {
+			logrus.Warnf("%v: invalid sub-seconds in %v (%v), ignored", fm.File, df.SubSecField, err)
+		} else {
+			t = t.Add(ns)
+		}
+	}
+	return t, true, nil
+}
+
+// subSeconds parses the digits of a fraction of second (123 -> 123ms)
+func subSeconds(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 9 {
+		s = s[:9]
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid fraction of second %v", s)
+	}
+	for i := len(s); i < 9; i++ {
+		v *= 10
+	}
+	return time.Duration(v), nil
+}
+
+// layouts returns the golang layouts of the field, presets being resolved
+func (df DateField) layouts() []string {
+	layouts := make([]string, len(df.Patterns))
+	for i, p := range df.Patterns {
+		if l, found := DatePresets[p]; found {
+			layouts[i] = l
+		} else {
+			layouts[i] = p
+		}
+	}
+	return layouts
+}
+
+// parse parses a value with the first matching layout, in the timezone of the field if it doesn't
+// hold its offset
+func (df DateField) parse(s string) (time.Time, error) {
+	return df.parseWithOffset(s, "")
+}
+
+// parseWithOffset parses a value with the first matching layout, the offset (+02:00) being used if
+// the value doesn't hold its own one
+func (df DateField) parseWithOffset(s string, offset string) (time.Time, error) {
+	errs := []string{}
+	for _, l := range df.layouts() {
+		t, err := time.ParseInLocation(l, s, time.UTC)
+		if err == nil {
+			probe, _ := time.ParseInLocation(l, s, probeZone)
+			return df.localize(t, t.Equal(probe), offset)
+		}
+		errs = append(errs, err.Error())
+	}
+	return time.Time{}, fmt.Errorf("error when parsing date %v: %v", s, strings.Join(errs, ", "))
+}
+
+// guessDate returns the date of the file according to the date fields of the default rule (the ones
+// applied to the files matching no rule)
+func (cl *Classifier) guessDate(fm FileMetadata) (time.Time, DateField, error) {
+	return guessDate(fm, cl.settings(nil).dateFields)
+}
+
+// guessDate returns the date held by the first date field (in priority order) found in the metadata
+// with a valid value, and this field. Unparsable or zero values are logged and the next field is tried.
+// If no field is found (zero values of the fields skipping them count as not found), errNoDateFount is
+// returned, otherwise the error lists why every field has been rejected.
+func guessDate(fm FileMetadata, dateFields []DateField) (time.Time, DateField, error) {
+	rejections := []string{}
+	foundCount := 0
+	for _, df := range dateFields {
+		t, found, err := df.date(fm)
+		if !found {
+			rejections = append(rejections, fmt.Sprintf("%v: not found", df.name()))
+			continue
+		}
+		if _, zero := err.(zeroDateError); zero && df.SkipZero {
+			rejections = append(rejections, fmt.Sprintf("%v: %v", df.name(), err))
+			continue
+		}
+		foundCount++
+		if err != nil {
+			rejections = append(rejections, fmt.Sprintf("%v: %v", df.name(), err))
+			logrus.Warnf("%v: invalid date in %v (%v), trying next field", fm.File, df.name(), err)
+			continue
+		}
+		return t, df, nil
+	}
+	if foundCount == 0 {
+		return time.Time{}, DateField{}, errNoDateFount
+	}
+	return time.Time{}, DateField{}, fmt.Errorf("no valid date found (%v)", strings.Join(rejections, "; "))
+}
+
+// isZeroDate checks if the date part of a value only contains zero digits (or is empty), as some
+// cameras write 0000:00:00 00:00:00 when their clock is not set
+func isZeroDate(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return true
+	}
+	if i := strings.IndexAny(s, " T"); i >= 0 {
+		s = s[:i]
+	}
+	zeros := false
+	for _, r := range s {
+		if r >= '1' && r <= '9' {
+			return false
+		}
+		zeros = zeros || r == '0'
+	}
+	return zeros
+}
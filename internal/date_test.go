@@ -0,0 +1,204 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testExifPattern = "2006:01:02 15:04:05"
+
+func TestGuessDateNominal(t *testing.T) {
+	fields := map[string]interface{}{
+		"a":          "b",
+		"CreateDate": "2018:01:02 03:04:05",
+	}
+	fm := FileMetadata{File: "a", Fields: fields}
+	c := buildDefaultClassifier(t, 2)
+	got, _, err := c.guessDate(fm)
+	assert.Nil(t, err)
+	assert.Equal(t, 2018, got.Year())
+	assert.Equal(t, time.January, got.Month())
+	assert.Equal(t, 2, got.Day())
+	assert.Equal(t, 3, got.Hour())
+	assert.Equal(t, 4, got.Minute())
+	assert.Equal(t, 5, got.Second())
+}
+
+func TestGuessDateWithoutDateField(t *testing.T) {
+	fields := map[string]interface{}{
+		"a": "b",
+	}
+	fm := FileMetadata{File: "a", Fields: fields}
+	c := buildDefaultClassifier(t, 2)
+	_, _, err := c.guessDate(fm)
+	assert.Equal(t, errNoDateFount, err)
+}
+
+func TestGuessDateUnparsableDate(t *testing.T) {
+	fields := map[string]interface{}{
+		"a":          "b",
+		"CreateDate": "unparsableDate",
+	}
+	fm := FileMetadata{File: "a", Fields: fields}
+	c := buildDefaultClassifier(t, 2)
+	_, _, err := c.guessDate(fm)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, errNoDateFount, err)
+}
+
+func TestGuessDatePriority(t *testing.T) {
+	fields := map[string]interface{}{
+		"CreateDate":        "2018:01:02 03:04:05",
+		"Media Create Date": "2017:01:02 03:04:05",
+		"ZeroDate":          "0000:00:00 00:00:00",
+		"Unparsable":        "unparsableDate",
+	}
+	fm := FileMetadata{File: "a", Fields: fields}
+	var tcs = []struct {
+		tcID     string
+		fields   []DateField
+		expYear  int
+		expError bool
+	}{
+		{"createDateFirst", []DateField{{Field: "CreateDate", Patterns: []string{testExifPattern}}, {Field: "Media Create Date", Patterns: []string{testExifPattern}}}, 2018, false},
+		{"mediaCreateDateFirst", []DateField{{Field: "Media Create Date", Patterns: []string{testExifPattern}}, {Field: "CreateDate", Patterns: []string{testExifPattern}}}, 2017, false},
+		{"missingFirst", []DateField{{Field: "Missing", Patterns: []string{testExifPattern}}, {Field: "Media Create Date", Patterns: []string{testExifPattern}}}, 2017, false},
+		{"zeroSkipped", []DateField{{Field: "ZeroDate", Patterns: []string{testExifPattern}, SkipZero: true}, {Field: "CreateDate", Patterns: []string{testExifPattern}}}, 2018, false},
+		{"zeroNotSkipped", []DateField{{Field: "ZeroDate", Patterns: []string{testExifPattern}}, {Field: "CreateDate", Patterns: []string{testExifPattern}}}, 2018, false},
+		{"unparsableFirst", []DateField{{Field: "Unparsable", Patterns: []string{testExifPattern}}, {Field: "Media Create Date", Patterns: []string{testExifPattern}}}, 2017, false},
+		{"onlyInvalid", []DateField{{Field: "Unparsable", Patterns: []string{testExifPattern}}, {Field: "ZeroDate", Patterns: []string{testExifPattern}}}, 0, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			c, err := NewClassifier(OptDateFields(tc.fields))
+			assert.Nil(t, err)
+			// map iteration order is random, several runs ensure that the priority is honored
+			for i := 0; i < 20; i++ {
+				got, _, err := c.guessDate(fm)
+				assert.Equal(t, tc.expError, err != nil)
+				if !tc.expError {
+					assert.Equal(t, tc.expYear, got.Year())
+				}
+			}
+		})
+	}
+}
+
+func TestOptDateFieldsPerClassifier(t *testing.T) {
+	first := []DateField{{Field: "CreateDate", Patterns: []string{testExifPattern}}}
+	second := []DateField{{Field: "Media Create Date", Patterns: []string{testExifPattern}}}
+	c1, err := NewClassifier(OptDateFields(first))
+	assert.Nil(t, err)
+	c2, err := NewClassifier(OptDateFields(second))
+	assert.Nil(t, err)
+	assert.Equal(t, first, c1.dateFields)
+	assert.Equal(t, second, c2.dateFields)
+}
+
+func TestIsZeroDate(t *testing.T) {
+	assert.True(t, isZeroDate("0000:00:00 00:00:00"))
+	assert.True(t, isZeroDate("0000:00:00 00:00:00+02:00"))
+	assert.True(t, isZeroDate("0000-00-00T00:00:00"))
+	assert.True(t, isZeroDate("  "))
+	assert.False(t, isZeroDate("2019:04:04 00:00:00"))
+	assert.False(t, isZeroDate("0000:00:01 00:00:00"))
+	assert.False(t, isZeroDate("unparsableDate"))
+	assert.False(t, isZeroDate("Tomorrow"))
+}
+
+func TestDateFieldParse(t *testing.T) {
+	paris := time.FixedZone("", 2*3600)
+	var tcs = []struct {
+		tcID     string
+		patterns []string
+		value    string
+		expTime  time.Time
+		expError bool
+	}{
+		{"exif", []string{"exif"}, "2019:04:04 13:18:04", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), false},
+		{"exifTz", []string{"exif-tz"}, "2019:04:04 13:18:04+02:00", time.Date(2019, 4, 4, 13, 18, 4, 0, paris), false},
+		{"exifSubsec", []string{"exif-subsec"}, "2019:04:04 13:18:04.123", time.Date(2019, 4, 4, 13, 18, 4, 123000000, time.UTC), false},
+		{"rfc3339", []string{"rfc3339"}, "2019-04-04T13:18:04+02:00", time.Date(2019, 4, 4, 13, 18, 4, 0, paris), false},
+		{"golangLayout", []string{"02/01/2006"}, "04/05/2019", time.Date(2019, 5, 4, 0, 0, 0, 0, time.UTC), false},
+		{"secondPattern", []string{"exif-tz", "exif"}, "2019:04:04 13:18:04", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), false},
+		{"noMatchingPattern", []string{"exif-tz", "rfc3339"}, "2019:04:04 13:18:04", time.Time{}, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			got, err := DateField{Field: "f", Patterns: tc.patterns}.parse(tc.value)
+			assert.Equal(t, tc.expError, err != nil)
+			assert.True(t, tc.expTime.Equal(got), "expected %v, got %v", tc.expTime, got)
+		})
+	}
+}
+
+func TestOptDateFieldsWithoutPattern(t *testing.T) {
+	_, err := NewClassifier(OptDateFields([]DateField{{Field: "a"}}))
+	assert.NotNil(t, err)
+}
+
+func TestGuessDateDiagnostic(t *testing.T) {
+	fields := map[string]interface{}{
+		"ZeroDate":   "0000:00:00 00:00:00",
+		"Unparsable": "unparsableDate",
+	}
+	fm := FileMetadata{File: "a", Fields: fields}
+	c, err := NewClassifier(OptDateFields([]DateField{
+		{Field: "Unparsable", Patterns: []string{testExifPattern}},
+		{Field: "Missing", Patterns: []string{testExifPattern}},
+		{Field: "ZeroDate", Patterns: []string{testExifPattern}},
+	}))
+	assert.Nil(t, err)
+
+	_, _, err = c.guessDate(fm)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, errNoDateFount, err)
+	assert.Contains(t, err.Error(), "Unparsable: error when parsing date unparsableDate")
+	assert.Contains(t, err.Error(), "Missing: not found")
+	assert.Contains(t, err.Error(), "ZeroDate: zero date 0000:00:00 00:00:00")
+}
+
+func TestGuessDateSkippedZeroOnly(t *testing.T) {
+	fm := FileMetadata{File: "a", Fields: map[string]interface{}{"ZeroDate": "0000:00:00 00:00:00"}}
+	c, err := NewClassifier(OptDateFields([]DateField{
+		{Field: "ZeroDate", Patterns: []string{testExifPattern}, SkipZero: true},
+		{Field: "Missing", Patterns: []string{testExifPattern}},
+	}))
+	assert.Nil(t, err)
+
+	_, _, err = c.guessDate(fm)
+	assert.Equal(t, errNoDateFount, err)
+}
+
+func TestMetadataDateSubSeconds(t *testing.T) {
+	var tcs = []struct {
+		tcID    string
+		value   string
+		subSec  interface{}
+		expTime time.Time
+	}{
+		{"noSubSec", "2019:04:04 13:18:04", nil, time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC)},
+		{"subSec", "2019:04:04 13:18:04", "12", time.Date(2019, 4, 4, 13, 18, 4, 120000000, time.UTC)},
+		{"numericSubSec", "2019:04:04 13:18:04", 345, time.Date(2019, 4, 4, 13, 18, 4, 345000000, time.UTC)},
+		{"valueWins", "2019:04:04 13:18:04.5", "12", time.Date(2019, 4, 4, 13, 18, 4, 500000000, time.UTC)},
+		{"invalidSubSec", "2019:04:04 13:18:04", "x", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC)},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			df := DateField{Field: "DateTimeOriginal", Patterns: []string{"exif-subsec"}, SubSecField: "SubSecTimeOriginal"}
+			assert.Nil(t, df.init())
+			fields := map[string]interface{}{"DateTimeOriginal": tc.value}
+			if tc.subSec != nil {
+				fields["SubSecTimeOriginal"] = tc.subSec
+			}
+			got, found, err := df.metadataDate(FileMetadata{Fields: fields})
+			assert.True(t, found)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expTime, got)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package classifier
+
+import (
+	"fmt"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/sirupsen/logrus"
+)
+
+// exiftoolCrashMsg is the error reported by go-exiftool when the exiftool process does not answer anymore
+const exiftoolCrashMsg = "nothing on stdout"
+
+// FileMetadata is the result of a metadata extraction for a single file. File contains the
+// extracted filename, Fields the extracted tags and Err is not nil if anything went wrong.
+type FileMetadata struct {
+	File   string
+	Fields map[string]interface{}
+	Err    error
+}
+
+// MetadataExtractor extracts metadata from batches of files
+type MetadataExtractor interface {
+	// ExtractMetadata returns one FileMetadata per provided file, in the same order
+	ExtractMetadata(files ...string) []FileMetadata
+	// Close releases the resources held by the extractor
+	Close() error
+}
+
+// ExtractorFactory instanciates a new MetadataExtractor
+type ExtractorFactory func() (MetadataExtractor, error)
+
+// exiftoolProcess is a running exiftool instance
+type exiftoolProcess interface {
+	ExtractMetadata(files ...string) []exiftool.FileMetadata
+	Close() error
+}
+
+// exiftoolExtractor is a MetadataExtractor that keeps a single exiftool process (-stay_open) alive
+// across batches and restarts it when it crashes
+type exiftoolExtractor struct {
+	start   func() (exiftoolProcess, error)
+	process exiftoolProcess
+}
+
+func startExiftool() (exiftoolProcess, error) {
+	return exiftool.NewExiftool()
+}
+
+// NewExiftoolExtractor instanciates a MetadataExtractor backed by exiftool
+func NewExiftoolExtractor() (MetadataExtractor, error) {
+	return newExiftoolExtractor(startExiftool)
+}
+
+func newExiftoolExtractor(start func() (exiftoolProcess, error)) (*exiftoolExtractor, error) {
+	e := exiftoolExtractor{start: start}
+	if err := e.restart(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (e *exiftoolExtractor) restart() error {
+	if e.process != nil {
+		if err := e.process.Close(); err != nil {
+			logrus.Debugf("error while closing crashed exiftool: %v", err)
+		}
+		e.process = nil
+	}
+	p, err := e.start()
+	if err != nil {
+		return fmt.Errorf("error while intializing exiftool: %v", err)
+	}
+	e.process = p
+	return nil
+}
+
+// ExtractMetadata extracts metadata from files. If exiftool crashes, it is restarted and the
+// extraction resumes from the file that was being processed. A file that makes exiftool crash
+// twice in a row is reported as an error.
+func (e *exiftoolExtractor) ExtractMetadata(files ...string) []FileMetadata {
+	fms := make([]FileMetadata, len(files))
+	if e.process == nil {
+		if err := e.restart(); err != nil {
+			failRemaining(fms, files, 0, err)
+			return fms
+		}
+	}
+
+	lastCrash := -1
+	done := 0
+	for done < len(files) {
+		crashed := false
+		for _, efm := range e.process.ExtractMetadata(files[done:]...) {
+			if efm.Err != nil && efm.Err.Error() == exiftoolCrashMsg {
+				crashed = true
+				break
+			}
+			fms[done] = FileMetadata{File: efm.File, Fields: efm.Fields, Err: efm.Err}
+			done++
+		}
+		if !crashed {
+			continue
+		}
+
+		logrus.Warnf("exiftool crashed while extracting %v, restarting", files[done])
+		if done == lastCrash {
+			fms[done] = FileMetadata{File: files[done], Err: fmt.Errorf("exiftool crashed twice while extracting metadata")}
+			done++
+			lastCrash = -1
+		} else {
+			lastCrash = done
+		}
+		if err := e.restart(); err != nil {
+			failRemaining(fms, files, done, err)
+			return fms
+		}
+	}
+	return fms
+}
+
+func failRemaining(fms []FileMetadata, files []string, from int, err error) {
+	for i := from; i < len(files); i++ {
+		fms[i] = FileMetadata{File: files[i], Err: err}
+	}
+}
+
+// Close stops the exiftool process
+func (e *exiftoolExtractor) Close() error {
+	if e.process == nil {
+		return nil
+	}
+	err := e.process.Close()
+	e.process = nil
+	return err
+}
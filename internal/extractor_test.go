@@ -0,0 +1,166 @@
+package classifier
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/barasher/go-exiftool"
+	"github.com/stretchr/testify/assert"
+)
+
+func checkExiftool(t testing.TB) {
+	if _, err := exec.LookPath("exiftool"); err != nil {
+		t.Skip("exiftool is not installed")
+	}
+}
+
+func TestExiftoolExtractor(t *testing.T) {
+	checkExiftool(t)
+	e, err := NewExiftoolExtractor()
+	assert.Nil(t, err)
+	defer e.Close()
+
+	fms := e.ExtractMetadata("../testdata/input/20190404_131804.jpg", "../testdata/input/nonExisting.jpg")
+	assert.Len(t, fms, 2)
+	assert.Equal(t, "../testdata/input/20190404_131804.jpg", fms[0].File)
+	assert.Nil(t, fms[0].Err)
+	assert.Equal(t, "2019:04:04 13:18:03", fms[0].Fields["CreateDate"])
+	assert.Equal(t, "../testdata/input/nonExisting.jpg", fms[1].File)
+	assert.NotNil(t, fms[1].Err)
+}
+
+// fakeExiftoolProcess simulates an exiftool process that crashes when it meets the files listed in crashOn
+type fakeExiftoolProcess struct {
+	crashOn map[string]int
+	closed  bool
+}
+
+func (p *fakeExiftoolProcess) ExtractMetadata(files ...string) []exiftool.FileMetadata {
+	fms := make([]exiftool.FileMetadata, len(files))
+	crashed := false
+	for i, f := range files {
+		fms[i].File = f
+		if !crashed && p.crashOn[f] > 0 {
+			p.crashOn[f]--
+			crashed = true
+		}
+		if crashed {
+			fms[i].Err = fmt.Errorf(exiftoolCrashMsg)
+			continue
+		}
+		fms[i].Fields = map[string]interface{}{"FileName": f}
+	}
+	return fms
+}
+
+func (p *fakeExiftoolProcess) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestExiftoolExtractorRestart(t *testing.T) {
+	var tcs = []struct {
+		tcID        string
+		crashOn     map[string]int
+		expErrors   []bool
+		expStarts   int
+		startErrors bool
+	}{
+		{"noCrash", map[string]int{}, []bool{false, false, false}, 1, false},
+		{"singleCrash", map[string]int{"b": 1}, []bool{false, false, false}, 2, false},
+		{"doubleCrash", map[string]int{"b": 2}, []bool{false, true, false}, 3, false},
+		{"restartFailure", map[string]int{"b": 1}, []bool{false, true, true}, 2, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			starts := 0
+			processes := []*fakeExiftoolProcess{}
+			start := func() (exiftoolProcess, error) {
+				starts++
+				if tc.startErrors && starts > 1 {
+					return nil, fmt.Errorf("error")
+				}
+				p := &fakeExiftoolProcess{crashOn: tc.crashOn}
+				processes = append(processes, p)
+				return p, nil
+			}
+
+			e, err := newExiftoolExtractor(start)
+			assert.Nil(t, err)
+			fms := e.ExtractMetadata("a", "b", "c")
+			assert.Len(t, fms, 3)
+			for i, f := range []string{"a", "b", "c"} {
+				assert.Equal(t, f, fms[i].File)
+				assert.Equal(t, tc.expErrors[i], fms[i].Err != nil)
+			}
+			assert.Equal(t, tc.expStarts, starts)
+			assert.Nil(t, e.Close())
+			for _, p := range processes {
+				assert.True(t, p.closed)
+			}
+		})
+	}
+}
+
+func TestNewExiftoolExtractorFailure(t *testing.T) {
+	_, err := newExiftoolExtractor(func() (exiftoolProcess, error) {
+		return nil, fmt.Errorf("error")
+	})
+	assert.NotNil(t, err)
+}
+
+func benchmarkFiles(b *testing.B) []string {
+	dir, err := ioutil.TempDir("", "benchExtraction")
+	if err != nil {
+		b.Fatal(err)
+	}
+	files := make([]string, 50)
+	for i := range files {
+		files[i] = filepath.Join(dir, fmt.Sprintf("%v.jpg", i))
+		if err := copy("../testdata/input/20190404_131804.jpg", files[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return files
+}
+
+// BenchmarkExtractionProcessPerBatch measures the former behaviour : one exiftool process per batch
+func BenchmarkExtractionProcessPerBatch(b *testing.B) {
+	checkExiftool(b)
+	files := benchmarkFiles(b)
+	defer os.RemoveAll(filepath.Dir(files[0]))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := 0; i < len(files); i += 10 {
+			e, err := NewExiftoolExtractor()
+			if err != nil {
+				b.Fatal(err)
+			}
+			e.ExtractMetadata(files[i : i+10]...)
+			e.Close()
+		}
+	}
+}
+
+// BenchmarkExtractionPersistentProcess measures a single exiftool process reused by every batch
+func BenchmarkExtractionPersistentProcess(b *testing.B) {
+	checkExiftool(b)
+	files := benchmarkFiles(b)
+	defer os.RemoveAll(filepath.Dir(files[0]))
+	e, err := NewExiftoolExtractor()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer e.Close()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := 0; i < len(files); i += 10 {
+			e.ExtractMetadata(files[i : i+10]...)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package classifier
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultFileNameRegexps are the regular expressions used to extract dates from file names when none is
+// specified. They match names such as 20190404_131804.jpg (Android), IMG-20190404-WA0001.jpg (WhatsApp),
+// Screenshot_20190404-131804.png or "Screen Recording 2019-04-04 at 13.18.04.mov".
+var DefaultFileNameRegexps = []string{
+	`(?P<year>(?:19|20)\d{2})[-_.]?(?P<month>0[1-9]|1[0-2])[-_.]?(?P<day>0[1-9]|[12]\d|3[01])(?:(?:[-_ T.]|\sat\s)(?P<hour>[01]\d|2[0-3])[-_.:h]?(?P<minute>[0-5]\d)[-_.:m]?(?P<second>[0-5]\d))?`,
+}
+
+var requiredDateGroups = []string{"year", "month", "day"}
+
+func (df *DateField) compileRegexps() error {
+	exprs := df.Regexps
+	if len(exprs) == 0 {
+		exprs = DefaultFileNameRegexps
+	}
+	df.regexps = make([]*regexp.Regexp, len(exprs))
+	for i, expr := range exprs {
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("error while compiling regular expression %v: %v", expr, err)
+		}
+		for _, g := range requiredDateGroups {
+			if !hasGroup(r, g) {
+				return fmt.Errorf("regular expression %v doesn't define the %v group", expr, g)
+			}
+		}
+		df.regexps[i] = r
+	}
+	return nil
+}
+
+// fileNameDate extracts the date from the file name with the first matching regular expression
+func (df DateField) fileNameDate(file string) (time.Time, bool, error) {
+	name := filepath.Base(file)
+	for _, r := range df.regexps {
+		m := r.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		values := map[string]int{}
+		for i, g := range r.SubexpNames() {
+			if g == "" || m[i] == "" {
+				continue
+			}
+			v, err := strconv.Atoi(m[i])
+			if err != nil {
+				return time.Time{}, true, fmt.Errorf("invalid %v in %v: %v", g, name, err)
+			}
+			values[g] = v
+		}
+		t := time.Date(values["year"], time.Month(values["month"]), values["day"], values["hour"], values["minute"], values["second"], 0, time.UTC)
+		if t.Month() != time.Month(values["month"]) || t.Day() != values["day"] || t.Hour() != values["hour"] || t.Minute() != values["minute"] || t.Second() != values["second"] {
+			return time.Time{}, true, fmt.Errorf("invalid date in %v", name)
+		}
+		t, err := df.localize(t, false, "")
+		return t, true, err
+	}
+	return time.Time{}, false, nil
+}
+
+func hasGroup(r *regexp.Regexp, group string) bool {
+	for _, g := range r.SubexpNames() {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
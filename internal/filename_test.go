@@ -0,0 +1,83 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNameDate(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		regexps  []string
+		file     string
+		expTime  time.Time
+		expFound bool
+		expError bool
+	}{
+		{"android", nil, "/a/20190404_131804.jpg", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), true, false},
+		{"whatsapp", nil, "/a/IMG-20190404-WA0001.jpg", time.Date(2019, 4, 4, 0, 0, 0, 0, time.UTC), true, false},
+		{"screenshot", nil, "Screenshot_20190404-131804.png", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), true, false},
+		{"screenRecording", nil, "Screen Recording 2019-04-04 at 13.18.04.mov", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), true, false},
+		{"noDate", nil, "/a/IMG_1234.jpg", time.Time{}, false, false},
+		{"folderIgnored", nil, "/20190404/IMG_1234.jpg", time.Time{}, false, false},
+		{"custom", []string{`^(?P<day>\d{2})(?P<month>\d{2})(?P<year>\d{4})`}, "04052019.jpg", time.Date(2019, 5, 4, 0, 0, 0, 0, time.UTC), true, false},
+		{"secondRegexp", []string{`^x(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`, `^y(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`}, "y20190405.jpg", time.Date(2019, 4, 5, 0, 0, 0, 0, time.UTC), true, false},
+		{"invalidDate", []string{`^(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})`}, "20190231.jpg", time.Time{}, true, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			df := DateField{Source: SourceFileName, Regexps: tc.regexps}
+			assert.Nil(t, df.init())
+			got, found, err := df.fileNameDate(tc.file)
+			assert.Equal(t, tc.expFound, found)
+			assert.Equal(t, tc.expError, err != nil)
+			assert.Equal(t, tc.expTime, got)
+		})
+	}
+}
+
+func TestFileNameDateFieldInit(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		regexps  []string
+		expError bool
+	}{
+		{"default", nil, false},
+		{"unparsable", []string{`(?P<year>\d{4}`}, true},
+		{"missingGroup", []string{`(?P<year>\d{4})(?P<month>\d{2})`}, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			df := DateField{Source: SourceFileName, Regexps: tc.regexps}
+			assert.Equal(t, tc.expError, df.init() != nil)
+		})
+	}
+}
+
+func TestGuessDateFileNameFallback(t *testing.T) {
+	c, err := NewClassifier(OptDateFields([]DateField{
+		{Field: "CreateDate", Patterns: []string{"exif"}},
+		{Source: SourceFileName},
+	}))
+	assert.Nil(t, err)
+
+	got, _, err := c.guessDate(FileMetadata{File: "/a/IMG-20180102-WA0001.jpg", Fields: map[string]interface{}{}})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC), got)
+
+	got, _, err = c.guessDate(FileMetadata{File: "/a/IMG-20180102-WA0001.jpg", Fields: map[string]interface{}{"CreateDate": "2019:04:04 13:18:04"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2019, got.Year())
+
+	_, _, err = c.guessDate(FileMetadata{File: "/a/IMG_1234.jpg", Fields: map[string]interface{}{}})
+	assert.Equal(t, errNoDateFount, err)
+}
+
+func TestOptDateFieldsUnknownSource(t *testing.T) {
+	_, err := NewClassifier(OptDateFields([]DateField{{Source: "unknown"}}))
+	assert.NotNil(t, err)
+}
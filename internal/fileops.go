@@ -0,0 +1,290 @@
+package classifier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Operations that can be used to dispatch files
+const (
+	// OperationMove moves the files (default)
+	OperationMove = "move"
+	// OperationCopy copies the files, leaving the source tree untouched
+	OperationCopy = "copy"
+	// OperationHardlink creates hard links, falling back to copy when not supported
+	OperationHardlink = "hardlink"
+	// OperationSymlink creates symbolic links pointing to the source files
+	OperationSymlink = "symlink"
+	// OperationReflink creates copy-on-write clones, falling back to copy when not supported
+	OperationReflink = "reflink"
+)
+
+var operations = map[string]bool{
+	OperationMove:     true,
+	OperationCopy:     true,
+	OperationHardlink: true,
+	OperationSymlink:  true,
+	OperationReflink:  true,
+}
+
+// Ways a file can actually be dispatched
+const (
+	renamed    = "rename"
+	copied     = "copy"
+	hardlinked = "hardlink"
+	symlinked  = "symlink"
+	reflinked  = "reflink"
+)
+
+// tmpPrefixFormat is the prefix of the temporary files created in the destination folders
+const tmpPrefixFormat = ".%v."
+
+// copiedFileMode is the mode of the copied files, the same as the one os.Create would use with the usual umask
+const copiedFileMode os.FileMode = 0644
+
+// rename is os.Rename, overridable for testing purposes
+var rename = os.Rename
+
+// dispatch applies the operation to the file from and returns the way it has actually been done. The
+// attributes listed in p are preserved when the file content is duplicated.
+func dispatch(operation string, p Preservation, from string, to string) (string, error) {
+	switch operation {
+	case OperationCopy:
+		return copied, copyPreserving(from, to, p)
+	case OperationHardlink:
+		return hardlink(from, to, p)
+	case OperationSymlink:
+		return symlink(from, to)
+	case OperationReflink:
+		return reflinkOrCopy(from, to, p)
+	default:
+		return move(from, to, p)
+	}
+}
+
+// placeAtomically creates the file to with the create function, using a temporary name in the
+// destination folder which is then atomically renamed
+func placeAtomically(to string, create func(tmp string) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(to), fmt.Sprintf(tmpPrefixFormat, filepath.Base(to)))
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if err := os.Remove(tmp.Name()); err != nil {
+		return err
+	}
+	if err := create(tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), to); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(to))
+}
+
+func hardlink(from, to string, p Preservation) (string, error) {
+	err := placeAtomically(to, func(tmp string) error {
+		return os.Link(from, tmp)
+	})
+	if err == nil {
+		return hardlinked, nil
+	}
+	if !isLinkUnsupported(err) {
+		return "", err
+	}
+	logrus.Debugf("hard link not supported for %v (%v), copying", from, err)
+	return copied, copyPreserving(from, to, p)
+}
+
+func isLinkUnsupported(err error) bool {
+	le, ok := err.(*os.LinkError)
+	if !ok {
+		return false
+	}
+	switch le.Err {
+	case syscall.EXDEV, syscall.EPERM, syscall.EMLINK, syscall.EOPNOTSUPP:
+		return true
+	}
+	return false
+}
+
+func symlink(from, to string) (string, error) {
+	abs, err := filepath.Abs(from)
+	if err != nil {
+		return "", err
+	}
+	return symlinked, placeAtomically(to, func(tmp string) error {
+		return os.Symlink(abs, tmp)
+	})
+}
+
+func reflinkOrCopy(from, to string, p Preservation) (string, error) {
+	err := placeAtomically(to, func(tmp string) error {
+		return reflinkFile(from, tmp, p)
+	})
+	if err == nil {
+		return reflinked, nil
+	}
+	if !isReflinkUnsupported(err) {
+		return "", err
+	}
+	logrus.Debugf("reflink not supported for %v (%v), copying", from, err)
+	return copied, copyPreserving(from, to, p)
+}
+
+// errReflinkUnsupported is returned on the platforms where reflinks are not implemented
+var errReflinkUnsupported = fmt.Errorf("reflink is not supported on this platform")
+
+// isReflinkUnsupported checks if a reflink failed because the filesystem or the platform can't clone files
+func isReflinkUnsupported(err error) bool {
+	switch err {
+	case errReflinkUnsupported, syscall.EOPNOTSUPP, syscall.EXDEV, syscall.EINVAL, syscall.ENOTTY:
+		return true
+	}
+	return false
+}
+
+func reflinkFile(from, to string, p Preservation) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	fi, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	destination, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, copiedFileMode)
+	if err != nil {
+		return err
+	}
+	if err := reflink(source, destination); err != nil {
+		destination.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := destination.Sync(); err != nil {
+		destination.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := destination.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	if err := applyAttributes(fi, from, to, p); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return nil
+}
+
+// copy durably copies a file without preserving its attributes
+func copy(from, to string) error {
+	return copyPreserving(from, to, Preservation{})
+}
+
+// copyPreserving durably copies a file : the content is written to a temporary file in the destination
+// folder, synced to disk and compared to the source, the attributes listed in p are carried over and
+// the file is finally renamed to its final name
+func copyPreserving(from, to string, p Preservation) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	fi, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(to), fmt.Sprintf(tmpPrefixFormat, filepath.Base(to)))
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	h := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, h), source)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(copiedFileMode); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error while syncing %v: %v", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error while closing %v: %v", tmp.Name(), err)
+	}
+
+	if written != fi.Size() {
+		return fmt.Errorf("size mismatch after copy (%v bytes instead of %v)", written, fi.Size())
+	}
+	copiedHash, err := fileHash(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), copiedHash) {
+		return fmt.Errorf("checksum mismatch after copy")
+	}
+	if err := applyAttributes(fi, from, tmp.Name(), p); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), to); err != nil {
+		return err
+	}
+	committed = true
+	return syncDir(filepath.Dir(to))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("error while syncing folder %v: %v", dir, err)
+	}
+	return nil
+}
+
+// move moves a file, using an atomic rename when possible and falling back to a verified copy
+// followed by the deletion of the source when source and destination are on different devices.
+// It returns the way the file has been moved.
+func move(from, to string, p Preservation) (string, error) {
+	err := rename(from, to)
+	if err == nil {
+		return renamed, nil
+	}
+	if !isCrossDevice(err) {
+		return "", err
+	}
+	if err := copyPreserving(from, to, p); err != nil {
+		return "", err
+	}
+	return copied, os.Remove(from)
+}
+
+func isCrossDevice(err error) bool {
+	le, ok := err.(*os.LinkError)
+	return ok && le.Err == syscall.EXDEV
+}
@@ -0,0 +1,142 @@
+package classifier
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopy(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestCopy"
+	var tcs = []struct {
+		tcID     string
+		existing bool
+	}{
+		{"nominal", false},
+		{"overwrite", true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Nil(t, os.RemoveAll(dir))
+			writeFile(t, dir+"/in/a.jpg", "content")
+			assert.Nil(t, os.MkdirAll(dir+"/out", 0777))
+			if tc.existing {
+				writeFile(t, dir+"/out/a.jpg", "existing content")
+			}
+
+			assert.Nil(t, copy(dir+"/in/a.jpg", dir+"/out/a.jpg"))
+			checkExist(t, dir+"/in/a.jpg", true)
+			b, err := ioutil.ReadFile(dir + "/out/a.jpg")
+			assert.Nil(t, err)
+			assert.Equal(t, "content", string(b))
+			fis, err := ioutil.ReadDir(dir + "/out")
+			assert.Nil(t, err)
+			assert.Len(t, fis, 1)
+		})
+	}
+}
+
+func TestCopyFailure(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestCopyFailure"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/a.jpg", "content")
+
+	assert.NotNil(t, copy(dir+"/in/nonExisting.jpg", dir+"/in/b.jpg"))
+	assert.NotNil(t, copy(dir+"/in/a.jpg", dir+"/nonExistingFolder/a.jpg"))
+}
+
+func TestMove(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestMove"
+	crossDevice := func(from, to string) error {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EXDEV}
+	}
+	failure := func(from, to string) error {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EACCES}
+	}
+	var tcs = []struct {
+		tcID      string
+		rename    func(string, string) error
+		expMethod string
+		expErr    bool
+	}{
+		{"rename", os.Rename, renamed, false},
+		{"crossDevice", crossDevice, copied, false},
+		{"failure", failure, "", true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			defer func() { rename = os.Rename }()
+			rename = tc.rename
+			assert.Nil(t, os.RemoveAll(dir))
+			writeFile(t, dir+"/in/a.jpg", "a")
+			assert.Nil(t, os.MkdirAll(dir+"/out", 0777))
+
+			how, err := move(dir+"/in/a.jpg", dir+"/out/a.jpg", Preservation{})
+			assert.Equal(t, tc.expErr, err != nil)
+			assert.Equal(t, tc.expMethod, how)
+			checkExist(t, dir+"/in/a.jpg", tc.expErr)
+			checkExist(t, dir+"/out/a.jpg", !tc.expErr)
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestDispatch"
+	var tcs = []struct {
+		tcID         string
+		operation    string
+		expMethods   []string
+		expSrcExists bool
+		expSymlink   bool
+	}{
+		{"move", OperationMove, []string{renamed}, false, false},
+		{"copy", OperationCopy, []string{copied}, true, false},
+		{"hardlink", OperationHardlink, []string{hardlinked}, true, false},
+		{"symlink", OperationSymlink, []string{symlinked}, true, true},
+		{"reflink", OperationReflink, []string{reflinked, copied}, true, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Nil(t, os.RemoveAll(dir))
+			writeFile(t, dir+"/in/a.jpg", "content")
+			writeFile(t, dir+"/out/a.jpg", "existing content")
+
+			how, err := dispatch(tc.operation, PreserveAll, dir+"/in/a.jpg", dir+"/out/a.jpg")
+			assert.Nil(t, err)
+			assert.Contains(t, tc.expMethods, how)
+			checkExist(t, dir+"/in/a.jpg", tc.expSrcExists)
+			b, err := ioutil.ReadFile(dir + "/out/a.jpg")
+			assert.Nil(t, err)
+			assert.Equal(t, "content", string(b))
+			fi, err := os.Lstat(dir + "/out/a.jpg")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expSymlink, fi.Mode()&os.ModeSymlink != 0)
+			fis, err := ioutil.ReadDir(dir + "/out")
+			assert.Nil(t, err)
+			assert.Len(t, fis, 1)
+		})
+	}
+}
+
+func TestHardlinkFallback(t *testing.T) {
+	assert.True(t, isLinkUnsupported(&os.LinkError{Op: "link", Err: syscall.EXDEV}))
+	assert.True(t, isLinkUnsupported(&os.LinkError{Op: "link", Err: syscall.EPERM}))
+	assert.False(t, isLinkUnsupported(&os.LinkError{Op: "link", Err: syscall.ENOENT}))
+	assert.False(t, isLinkUnsupported(os.ErrNotExist))
+}
+
+func TestReflinkFallback(t *testing.T) {
+	assert.True(t, isReflinkUnsupported(syscall.EOPNOTSUPP))
+	assert.True(t, isReflinkUnsupported(syscall.EXDEV))
+	assert.True(t, isReflinkUnsupported(syscall.EINVAL))
+	assert.True(t, isReflinkUnsupported(syscall.ENOTTY))
+	assert.True(t, isReflinkUnsupported(errReflinkUnsupported))
+	assert.False(t, isReflinkUnsupported(syscall.ENOSPC))
+	assert.False(t, isReflinkUnsupported(syscall.EIO))
+}
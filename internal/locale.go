@@ -0,0 +1,102 @@
+package classifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// locale holds the month, weekday and season names of a language, weekdays starting on Sunday as
+// time.Weekday and seasons on winter. Abbreviations are written without trailing dot as they end up in
+// file names.
+type locale struct {
+	months        [12]string
+	shortMonths   [12]string
+	weekdays      [7]string
+	shortWeekdays [7]string
+	seasons       [4]string
+}
+
+// locales are the supported locales, by language code
+var locales = map[string]*locale{
+	"en": {
+		months:        [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:      [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		shortWeekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		seasons:       [4]string{"Winter", "Spring", "Summer", "Autumn"},
+	},
+	"fr": {
+		months:        [12]string{"Janvier", "Février", "Mars", "Avril", "Mai", "Juin", "Juillet", "Août", "Septembre", "Octobre", "Novembre", "Décembre"},
+		shortMonths:   [12]string{"Janv", "Févr", "Mars", "Avr", "Mai", "Juin", "Juil", "Août", "Sept", "Oct", "Nov", "Déc"},
+		weekdays:      [7]string{"Dimanche", "Lundi", "Mardi", "Mercredi", "Jeudi", "Vendredi", "Samedi"},
+		shortWeekdays: [7]string{"Dim", "Lun", "Mar", "Mer", "Jeu", "Ven", "Sam"},
+		seasons:       [4]string{"Hiver", "Printemps", "Été", "Automne"},
+	},
+	"de": {
+		months:        [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths:   [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		weekdays:      [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortWeekdays: [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		seasons:       [4]string{"Winter", "Frühling", "Sommer", "Herbst"},
+	},
+	"es": {
+		months:        [12]string{"Enero", "Febrero", "Marzo", "Abril", "Mayo", "Junio", "Julio", "Agosto", "Septiembre", "Octubre", "Noviembre", "Diciembre"},
+		shortMonths:   [12]string{"Ene", "Feb", "Mar", "Abr", "May", "Jun", "Jul", "Ago", "Sep", "Oct", "Nov", "Dic"},
+		weekdays:      [7]string{"Domingo", "Lunes", "Martes", "Miércoles", "Jueves", "Viernes", "Sábado"},
+		shortWeekdays: [7]string{"Dom", "Lun", "Mar", "Mié", "Jue", "Vie", "Sáb"},
+		seasons:       [4]string{"Invierno", "Primavera", "Verano", "Otoño"},
+	},
+}
+
+// defaultLocale is used when no locale is specified
+const defaultLocale = "en"
+
+// findLocale returns the locale of a language code, the region being ignored (fr_FR, fr-CA -> fr)
+func findLocale(name string) (*locale, error) {
+	lang := strings.ToLower(name)
+	if i := strings.IndexAny(lang, "_-."); i >= 0 {
+		lang = lang[:i]
+	}
+	l, found := locales[lang]
+	if !found {
+		return nil, fmt.Errorf("unknown locale %v", name)
+	}
+	return l, nil
+}
+
+func (l *locale) month(m time.Month) string {
+	return l.months[m-1]
+}
+
+func (l *locale) shortMonth(m time.Month) string {
+	return l.shortMonths[m-1]
+}
+
+func (l *locale) weekday(d time.Weekday) string {
+	return l.weekdays[d]
+}
+
+func (l *locale) shortWeekday(d time.Weekday) string {
+	return l.shortWeekdays[d]
+}
+
+func (l *locale) season(s int) string {
+	return l.seasons[s]
+}
+
+// OptLocale specifies the language of the month, weekday and season names of the templates (en, fr,
+// de, es), English being used if empty
+func OptLocale(name string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if name == "" {
+			name = defaultLocale
+		}
+		l, err := findLocale(name)
+		if err != nil {
+			return err
+		}
+		c.locale = l
+		return nil
+	}
+}
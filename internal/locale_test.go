@@ -0,0 +1,119 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindLocale(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		name     string
+		expMonth string
+		expError bool
+	}{
+		{"english", "en", "April", false},
+		{"french", "fr", "Avril", false},
+		{"german", "de", "April", false},
+		{"spanish", "es", "Abril", false},
+		{"region", "fr_FR", "Avril", false},
+		{"regionDash", "es-MX", "Abril", false},
+		{"encoding", "de_DE.UTF-8", "April", false},
+		{"upperCase", "FR", "Avril", false},
+		{"unknown", "it", "", true},
+		{"empty", "", "", true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			l, err := findLocale(tc.name)
+			assert.Equal(t, tc.expError, err != nil)
+			if !tc.expError {
+				assert.Equal(t, tc.expMonth, l.month(time.April))
+			}
+		})
+	}
+}
+
+func TestOptLocale(t *testing.T) {
+	c, err := NewClassifier()
+	assert.Nil(t, err)
+	assert.Equal(t, locales["en"], c.locale)
+
+	c, err = NewClassifier(OptLocale("fr"))
+	assert.Nil(t, err)
+	assert.Equal(t, locales["fr"], c.locale)
+
+	c, err = NewClassifier(OptLocale(""))
+	assert.Nil(t, err)
+	assert.Equal(t, locales["en"], c.locale)
+
+	_, err = NewClassifier(OptLocale("klingon"))
+	assert.NotNil(t, err)
+}
+
+func TestLocalizedTokens(t *testing.T) {
+	// 2019-04-07 is a Sunday
+	d := time.Date(2019, 4, 7, 13, 18, 4, 0, time.UTC)
+	var tcs = []struct {
+		tcID     string
+		locale   string
+		template string
+		expPath  string
+	}{
+		{"default", "", "{month} {monthabbr} {weekday} {weekdayabbr}", "April Apr Sunday Sun"},
+		{"english", "en", "{month} {monthabbr} {weekday} {weekdayabbr}", "April Apr Sunday Sun"},
+		{"french", "fr", "{month} {monthabbr} {weekday} {weekdayabbr}", "Avril Avr Dimanche Dim"},
+		{"german", "de", "{month} {monthabbr} {weekday} {weekdayabbr}", "April Apr Sonntag So"},
+		{"spanish", "es", "{month} {monthabbr} {weekday} {weekdayabbr}", "Abril Abr Domingo Dom"},
+		{"folders", "fr", "{date:2006}/{date:01} - {month}/{file}", "2019/04 - Avril/IMG_1234.JPG"},
+		{"case", "fr", "{month:lower}_{weekdayabbr:upper}", "avril_DIM"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			ctx := templateContext{date: d, from: "/in/IMG_1234.JPG"}
+			if tc.locale != "" {
+				l, err := findLocale(tc.locale)
+				assert.Nil(t, err)
+				ctx.locale = l
+			}
+			tmpl, err := parseTemplate(tc.template)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expPath, tmpl.evaluate(ctx))
+		})
+	}
+}
+
+func TestLocaleTables(t *testing.T) {
+	for name, l := range locales {
+		t.Run(name, func(t *testing.T) {
+			for m := time.January; m <= time.December; m++ {
+				assert.NotEmpty(t, l.month(m))
+				assert.NotEmpty(t, l.shortMonth(m))
+			}
+			for d := time.Sunday; d <= time.Saturday; d++ {
+				assert.NotEmpty(t, l.weekday(d))
+				assert.NotEmpty(t, l.shortWeekday(d))
+			}
+			for s := seasonWinter; s <= seasonAutumn; s++ {
+				assert.NotEmpty(t, l.season(s))
+			}
+		})
+	}
+	assert.Equal(t, time.December.String(), locales["en"].month(time.December))
+	assert.Equal(t, time.Saturday.String(), locales["en"].weekday(time.Saturday))
+}
+
+func TestPlanLocale(t *testing.T) {
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptLocale("fr"),
+		OptDestination("{date:2006}/{date:01} - {month}/{file}"),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan("../testdata/input/20190404_131804.jpg", "/out")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlannedMove{{From: "../testdata/input/20190404_131804.jpg", To: "/out/2019/04 - Avril/20190404_131804.jpg"}}, p.Moves)
+}
@@ -0,0 +1,79 @@
+package classifier
+
+import (
+	"fmt"
+	"time"
+)
+
+// Meteorological seasons (northern hemisphere), winter starting on December 1st
+const (
+	seasonWinter = iota
+	seasonSpring
+	seasonSummer
+	seasonAutumn
+)
+
+// quarter returns the calendar quarter of a date (1 to 4)
+func quarter(t time.Time) int {
+	return (int(t.Month())-1)/3 + 1
+}
+
+// half returns the calendar half-year of a date (1 or 2)
+func half(t time.Time) int {
+	return (int(t.Month())-1)/6 + 1
+}
+
+// fiscalMonth returns the position of the month of a date in its fiscal year (0 to 11) and the fiscal
+// year, named after the calendar year in which it ends
+func fiscalMonth(t time.Time, start time.Month) (int, int) {
+	if start < time.January || start > time.December {
+		start = time.January
+	}
+	m := int(t.Month()) - int(start)
+	year := t.Year()
+	if start != time.January && m >= 0 {
+		year++
+	}
+	if m < 0 {
+		m += 12
+	}
+	return m, year
+}
+
+// fiscalYear returns the fiscal year of a date, fiscal years starting on the first day of the start month
+func fiscalYear(t time.Time, start time.Month) int {
+	_, year := fiscalMonth(t, start)
+	return year
+}
+
+// fiscalQuarter returns the quarter of a date in its fiscal year (1 to 4)
+func fiscalQuarter(t time.Time, start time.Month) int {
+	m, _ := fiscalMonth(t, start)
+	return m/3 + 1
+}
+
+// season returns the meteorological season of a date and the year in which it starts (December 2019
+// to February 2020 being the winter of 2019)
+func season(t time.Time) (int, int) {
+	year := t.Year()
+	m := int(t.Month()) % 12
+	if t.Month() < time.March {
+		year--
+	}
+	return m / 3, year
+}
+
+// OptFiscalYearStart specifies the first month of the fiscal years used by the fiscal tokens of the
+// templates, January by default
+func OptFiscalYearStart(month time.Month) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if month == 0 {
+			month = time.January
+		}
+		if month < time.January || month > time.December {
+			return fmt.Errorf("invalid fiscal year start month %v", int(month))
+		}
+		c.fiscalYearStart = month
+		return nil
+	}
+}
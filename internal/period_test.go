@@ -0,0 +1,121 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriods(t *testing.T) {
+	var tcs = []struct {
+		tcID       string
+		date       time.Time
+		expQuarter int
+		expHalf    int
+		expSeason  int
+		expSYear   int
+	}{
+		{"january", time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), 1, 1, seasonWinter, 2019},
+		{"february", time.Date(2020, 2, 29, 23, 59, 59, 0, time.UTC), 1, 1, seasonWinter, 2019},
+		{"march", time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), 1, 1, seasonSpring, 2020},
+		{"june", time.Date(2020, 6, 30, 0, 0, 0, 0, time.UTC), 2, 1, seasonSummer, 2020},
+		{"july", time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC), 3, 2, seasonSummer, 2020},
+		{"november", time.Date(2020, 11, 30, 0, 0, 0, 0, time.UTC), 4, 2, seasonAutumn, 2020},
+		{"december", time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC), 4, 2, seasonWinter, 2020},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expQuarter, quarter(tc.date))
+			assert.Equal(t, tc.expHalf, half(tc.date))
+			s, year := season(tc.date)
+			assert.Equal(t, tc.expSeason, s)
+			assert.Equal(t, tc.expSYear, year)
+		})
+	}
+}
+
+func TestFiscalYear(t *testing.T) {
+	var tcs = []struct {
+		tcID       string
+		date       time.Time
+		start      time.Month
+		expYear    int
+		expQuarter int
+	}{
+		{"calendar", time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC), time.January, 2019, 4},
+		{"unspecified", time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC), 0, 2019, 4},
+		{"octoberStart", time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC), time.October, 2020, 1},
+		{"beforeOctoberStart", time.Date(2019, 9, 30, 0, 0, 0, 0, time.UTC), time.October, 2019, 4},
+		{"januaryBeforeOctoberStart", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.October, 2020, 2},
+		{"aprilStart", time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC), time.April, 2020, 1},
+		{"marchBeforeAprilStart", time.Date(2019, 3, 31, 0, 0, 0, 0, time.UTC), time.April, 2019, 4},
+		{"julyStart", time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC), time.July, 2020, 2},
+		{"decemberStart", time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC), time.December, 2020, 1},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expYear, fiscalYear(tc.date, tc.start))
+			assert.Equal(t, tc.expQuarter, fiscalQuarter(tc.date, tc.start))
+		})
+	}
+}
+
+func TestOptFiscalYearStart(t *testing.T) {
+	c, err := NewClassifier()
+	assert.Nil(t, err)
+	assert.Equal(t, time.January, c.fiscalYearStart)
+
+	c, err = NewClassifier(OptFiscalYearStart(time.October))
+	assert.Nil(t, err)
+	assert.Equal(t, time.October, c.fiscalYearStart)
+
+	c, err = NewClassifier(OptFiscalYearStart(0))
+	assert.Nil(t, err)
+	assert.Equal(t, time.January, c.fiscalYearStart)
+
+	_, err = NewClassifier(OptFiscalYearStart(13))
+	assert.NotNil(t, err)
+}
+
+func TestPeriodTokens(t *testing.T) {
+	ctx := templateContext{date: time.Date(2019, 12, 30, 13, 18, 4, 0, time.UTC), from: "/in/IMG_1234.JPG", fiscalYearStart: time.July}
+	var tcs = []struct {
+		tcID     string
+		template string
+		expPath  string
+	}{
+		{"isoWeek", "{isoyear}-W{isoweek}/{file}", "2020-W01/IMG_1234.JPG"},
+		{"quarter", "{date:2006}/Q{quarter}/{file}", "2019/Q4/IMG_1234.JPG"},
+		{"half", "{date:2006}/H{half}/{file}", "2019/H2/IMG_1234.JPG"},
+		{"yearDay", "{date:2006}-{yearday}/{file}", "2019-364/IMG_1234.JPG"},
+		{"fiscal", "FY{fiscalyear}/Q{fiscalquarter}/{file}", "FY2020/Q2/IMG_1234.JPG"},
+		{"season", "{seasonyear} {season}/{file}", "2019 Winter/IMG_1234.JPG"},
+		{"seasonCase", "{season:lower}/{file}", "winter/IMG_1234.JPG"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			tmpl, err := parseTemplate(tc.template)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expPath, tmpl.evaluate(ctx))
+		})
+	}
+
+	tmpl, err := parseTemplate("{yearday}_{season}")
+	assert.Nil(t, err)
+	ctx = templateContext{date: time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), locale: locales["fr"]}
+	assert.Equal(t, "121_Printemps", tmpl.evaluate(ctx))
+}
+
+func TestPlanPeriodTokens(t *testing.T) {
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptFiscalYearStart(time.October),
+		OptDestination("FY{fiscalyear}/Q{fiscalquarter}/W{isoweek}/{file}"),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan("../testdata/input/20190404_131804.jpg", "/out")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlannedMove{{From: "../testdata/input/20190404_131804.jpg", To: "/out/FY2019/Q3/W14/20190404_131804.jpg"}}, p.Moves)
+}
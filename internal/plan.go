@@ -0,0 +1,89 @@
+package classifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Plan lists the operations that a classification would perform
+type Plan struct {
+	Moves        []PlannedMove      `json:"moves"`
+	Unclassified []UnclassifiedFile `json:"unclassified"`
+}
+
+// PlannedMove is a file that would be moved from From to To. Reason is set when the file can't be
+// classified and is dispatched to the unknown date or error folders. LowConfidence is set when the
+// date is only an approximation (filesystem timestamp). Correction describes the clock correction
+// applied to the date. CompanionOf is the primary file followed by a companion file.
+type PlannedMove struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Reason        string `json:"reason,omitempty"`
+	LowConfidence bool   `json:"lowConfidence,omitempty"`
+	Correction    string `json:"correction,omitempty"`
+	CompanionOf   string `json:"companionOf,omitempty"`
+}
+
+// UnclassifiedFile is a file that would be left untouched because it can't be classified
+type UnclassifiedFile struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+func (p *Plan) sort() {
+	sort.Slice(p.Moves, func(i, j int) bool {
+		return p.Moves[i].From < p.Moves[j].From
+	})
+	sort.Slice(p.Unclassified, func(i, j int) bool {
+		return p.Unclassified[i].File < p.Unclassified[j].File
+	})
+}
+
+// WriteText writes a human readable version of the plan
+func (p Plan) WriteText(w io.Writer) error {
+	lowConfidenceCount := 0
+	for _, m := range p.Moves {
+		line := fmt.Sprintf("%v -> %v", m.From, m.To)
+		if m.Reason != "" {
+			line += fmt.Sprintf(" (%v)", m.Reason)
+		}
+		if m.LowConfidence {
+			line += " (low confidence)"
+			lowConfidenceCount++
+		}
+		if m.Correction != "" {
+			line += fmt.Sprintf(" (corrected: %v)", m.Correction)
+		}
+		if m.CompanionOf != "" {
+			line += fmt.Sprintf(" (companion of %v)", m.CompanionOf)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	if len(p.Unclassified) > 0 {
+		if _, err := fmt.Fprintln(w, "Unclassified file(s):"); err != nil {
+			return err
+		}
+		for _, u := range p.Unclassified {
+			if _, err := fmt.Fprintf(w, "%v : %v\n", u.File, u.Reason); err != nil {
+				return err
+			}
+		}
+	}
+	moves := fmt.Sprintf("%v move(s)", len(p.Moves))
+	if lowConfidenceCount > 0 {
+		moves += fmt.Sprintf(" (%v low confidence)", lowConfidenceCount)
+	}
+	_, err := fmt.Fprintf(w, "%v, %v unclassified file(s)\n", moves, len(p.Unclassified))
+	return err
+}
+
+// WriteJSON writes the plan as JSON
+func (p Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
@@ -0,0 +1,79 @@
+package classifier
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildTestPlan() Plan {
+	p := Plan{
+		Moves: []PlannedMove{
+			{From: "/in/b.jpg", To: "/out/2019_04/b.jpg"},
+			{From: "/in/a.jpg", To: "/out/2019_03/a.jpg"},
+			{From: "/in/d.txt", To: "/out/unknown/d.txt", Reason: reasonNoDate},
+			{From: "/in/e.mov", To: "/out/2019_05/e.mov", LowConfidence: true},
+			{From: "/in/f.jpg", To: "/out/2018_04/f.jpg", Correction: "-1y"},
+		},
+		Unclassified: []UnclassifiedFile{
+			{File: "/in/c.txt", Reason: "no date"},
+		},
+	}
+	p.sort()
+	return p
+}
+
+func TestPlanWriteText(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, buildTestPlan().WriteText(&b))
+	exp := "/in/a.jpg -> /out/2019_03/a.jpg\n" +
+		"/in/b.jpg -> /out/2019_04/b.jpg\n" +
+		"/in/d.txt -> /out/unknown/d.txt (no date)\n" +
+		"/in/e.mov -> /out/2019_05/e.mov (low confidence)\n" +
+		"/in/f.jpg -> /out/2018_04/f.jpg (corrected: -1y)\n" +
+		"Unclassified file(s):\n" +
+		"/in/c.txt : no date\n" +
+		"5 move(s) (1 low confidence), 1 unclassified file(s)\n"
+	assert.Equal(t, exp, b.String())
+}
+
+func TestPlanWriteJSON(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, buildTestPlan().WriteJSON(&b))
+	exp := `{
+  "moves": [
+    {
+      "from": "/in/a.jpg",
+      "to": "/out/2019_03/a.jpg"
+    },
+    {
+      "from": "/in/b.jpg",
+      "to": "/out/2019_04/b.jpg"
+    },
+    {
+      "from": "/in/d.txt",
+      "to": "/out/unknown/d.txt",
+      "reason": "no date"
+    },
+    {
+      "from": "/in/e.mov",
+      "to": "/out/2019_05/e.mov",
+      "lowConfidence": true
+    },
+    {
+      "from": "/in/f.jpg",
+      "to": "/out/2018_04/f.jpg",
+      "correction": "-1y"
+    }
+  ],
+  "unclassified": [
+    {
+      "file": "/in/c.txt",
+      "reason": "no date"
+    }
+  ]
+}
+`
+	assert.Equal(t, exp, b.String())
+}
@@ -0,0 +1,21 @@
+//go:build linux
+// +build linux
+
+package classifier
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request (linux/fs.h)
+const ficlone = 0x40049409
+
+// reflink clones the content of source into destination (copy-on-write)
+func reflink(source *os.File, destination *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, destination.Fd(), ficlone, source.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
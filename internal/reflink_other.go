@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package classifier
+
+import (
+	"os"
+)
+
+// reflink is only supported on linux
+func reflink(source *os.File, destination *os.File) error {
+	return errReflinkUnsupported
+}
@@ -0,0 +1,229 @@
+package classifier
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Rule dispatches the files it matches with its own settings. The empty settings are inherited from
+// the classifier, whose settings make up the default rule applied to the files matching no rule.
+type Rule struct {
+	// Name identifies the rule in the logs
+	Name             string
+	Match            RuleMatch
+	DateFields       []DateField
+	OutputDateFormat string
+	Destination      string
+	Rename           string
+	Operation        string
+}
+
+// RuleMatch describes the files a rule applies to. Every specified criterion must be met, a criterion
+// listing several values being met by any of them.
+type RuleMatch struct {
+	// Extensions are specified without dot and are case insensitive
+	Extensions []string
+	// MimeTypes can end with a wildcard (video/*). The MIMEType tag is used, or the extension if missing.
+	MimeTypes []string
+	// Tags are exiftool tags and the values they must hold
+	Tags map[string]string
+	// MinSize and MaxSize bound the file size in bytes, a zero MaxSize meaning unbounded
+	MinSize int64
+	MaxSize int64
+	// Paths are glob patterns (https://golang.org/pkg/path/filepath/#Match) matched against the path
+	// relative to the input folder, or against the file name if they don't contain any separator
+	Paths []string
+}
+
+// rule is a checked Rule, with its parsed templates and initialized date fields
+type rule struct {
+	Rule
+	dateFields  []DateField
+	destination *pathTemplate
+	rename      *pathTemplate
+}
+
+// dispatchSettings are the settings applied to a file : the ones of the first matching rule, completed
+// by the classifier ones
+type dispatchSettings struct {
+	dateFields       []DateField
+	outputDateFormat string
+	destination      *pathTemplate
+	rename           *pathTemplate
+	operation        string
+}
+
+func (r Rule) compile() (rule, error) {
+	cr := rule{Rule: r}
+	m := r.Match
+	if len(m.Extensions) == 0 && len(m.MimeTypes) == 0 && len(m.Tags) == 0 && m.MinSize == 0 && m.MaxSize == 0 && len(m.Paths) == 0 {
+		return cr, fmt.Errorf("no criterion specified in rule %v", r.Name)
+	}
+	if m.MinSize < 0 || m.MaxSize < 0 || (m.MaxSize > 0 && m.MaxSize < m.MinSize) {
+		return cr, fmt.Errorf("invalid size range in rule %v", r.Name)
+	}
+	for _, p := range m.Paths {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return cr, fmt.Errorf("invalid path pattern %v in rule %v: %v", p, r.Name, err)
+		}
+	}
+	for _, p := range m.MimeTypes {
+		if _, err := path.Match(p, ""); err != nil {
+			return cr, fmt.Errorf("invalid MIME type %v in rule %v: %v", p, r.Name, err)
+		}
+	}
+	if r.Operation != "" && !operations[r.Operation] {
+		return cr, fmt.Errorf("unknown operation %v in rule %v", r.Operation, r.Name)
+	}
+	var err error
+	if r.Destination != "" {
+		if cr.destination, err = parseTemplate(r.Destination); err != nil {
+			return cr, fmt.Errorf("invalid destination in rule %v: %v", r.Name, err)
+		}
+	}
+	if r.Rename != "" {
+		if cr.rename, err = parseRenameTemplate(r.Rename); err != nil {
+			return cr, fmt.Errorf("invalid rename in rule %v: %v", r.Name, err)
+		}
+	}
+	cr.dateFields = append([]DateField{}, r.DateFields...)
+	for i := range cr.dateFields {
+		if err := cr.dateFields[i].init(); err != nil {
+			return cr, fmt.Errorf("invalid date field in rule %v: %v", r.Name, err)
+		}
+	}
+	return cr, nil
+}
+
+// matches checks if the rule applies to a file, rel being its path relative to the input folder
+func (r rule) matches(fm FileMetadata, rel string) bool {
+	m := r.Match
+	if len(m.Extensions) > 0 && extIndex(fm.File, m.Extensions) < 0 {
+		return false
+	}
+	if len(m.MimeTypes) > 0 && !matchesAny(path.Match, m.MimeTypes, mimeType(fm)) {
+		return false
+	}
+	for tag, expected := range m.Tags {
+		val, found := fm.Fields[tag]
+		if !found || strings.TrimSpace(fmt.Sprintf("%v", val)) != expected {
+			return false
+		}
+	}
+	if m.MinSize > 0 || m.MaxSize > 0 {
+		info, err := os.Stat(fm.File)
+		if err != nil || info.Size() < m.MinSize || (m.MaxSize > 0 && info.Size() > m.MaxSize) {
+			return false
+		}
+	}
+	if len(m.Paths) > 0 {
+		if rel == "" || rel == "." {
+			rel = filepath.Base(fm.File)
+		}
+		name := filepath.Base(rel)
+		matched := false
+		for _, p := range m.Paths {
+			target := rel
+			if !strings.ContainsRune(p, filepath.Separator) {
+				target = name
+			}
+			if ok, _ := filepath.Match(p, target); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny checks if the value matches one of the patterns
+func matchesAny(match func(string, string) (bool, error), patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mimeType returns the MIME type of a file (without parameters), from its MIMEType tag or its extension
+func mimeType(fm FileMetadata) string {
+	if val, found := fm.Fields["MIMEType"]; found {
+		if s := strings.TrimSpace(fmt.Sprintf("%v", val)); s != "" {
+			return strings.ToLower(s)
+		}
+	}
+	t, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(fm.File))))
+	if err != nil {
+		return ""
+	}
+	return t
+}
+
+// OptRules specifies the rules dispatching the files with their own settings, the first matching rule
+// being applied. The files matching no rule are dispatched with the classifier settings.
+func OptRules(rules []Rule) func(*Classifier) error {
+	return func(c *Classifier) error {
+		c.rules = make([]rule, len(rules))
+		for i, r := range rules {
+			cr, err := r.compile()
+			if err != nil {
+				return err
+			}
+			c.rules[i] = cr
+		}
+		return nil
+	}
+}
+
+// matchingRule returns the first rule applying to a file, rel being its path relative to the input
+// folder, nil if none
+func (cl *Classifier) matchingRule(fm FileMetadata, rel string) *rule {
+	for i := range cl.rules {
+		if cl.rules[i].matches(fm, rel) {
+			return &cl.rules[i]
+		}
+	}
+	return nil
+}
+
+// settings returns the settings applied to the files matched by a rule, completed by the classifier
+// ones. A nil rule is the default rule.
+func (cl *Classifier) settings(r *rule) dispatchSettings {
+	s := dispatchSettings{
+		dateFields:       cl.dateFields,
+		outputDateFormat: cl.outputDateFormat,
+		destination:      cl.destination,
+		rename:           cl.rename,
+		operation:        cl.operation,
+	}
+	if r == nil {
+		return s
+	}
+	if len(r.dateFields) > 0 {
+		s.dateFields = r.dateFields
+	}
+	if r.OutputDateFormat != "" {
+		s.outputDateFormat = r.OutputDateFormat
+	}
+	if r.destination != nil {
+		s.destination = r.destination
+	} else if r.OutputDateFormat != "" {
+		// the output date format of the rule prevails over the destination of the classifier
+		s.destination = nil
+	}
+	if r.rename != nil {
+		s.rename = r.rename
+	}
+	if r.Operation != "" {
+		s.operation = r.Operation
+	}
+	return s
+}
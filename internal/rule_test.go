@@ -0,0 +1,161 @@
+package classifier
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleCompile(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		rule     Rule
+		expError bool
+	}{
+		{"extension", Rule{Match: RuleMatch{Extensions: []string{"mp4"}}}, false},
+		{"full", Rule{Match: RuleMatch{MimeTypes: []string{"video/*"}, Paths: []string{"*/Screenshots/*"}, MinSize: 10, MaxSize: 20}, DateFields: []DateField{{Source: SourceFileName}}, Destination: "videos/{date:2006}/{file}", Rename: "{name}_{counter}{ext}", Operation: OperationCopy}, false},
+		{"noCriterion", Rule{Destination: "videos/{file}"}, true},
+		{"negativeSize", Rule{Match: RuleMatch{MinSize: -1}}, true},
+		{"emptySizeRange", Rule{Match: RuleMatch{MinSize: 20, MaxSize: 10}}, true},
+		{"invalidPath", Rule{Match: RuleMatch{Paths: []string{"[a"}}}, true},
+		{"invalidMimeType", Rule{Match: RuleMatch{MimeTypes: []string{"video/[a"}}}, true},
+		{"unknownOperation", Rule{Match: RuleMatch{Extensions: []string{"mp4"}}, Operation: "teleport"}, true},
+		{"invalidDestination", Rule{Match: RuleMatch{Extensions: []string{"mp4"}}, Destination: "{unknown}"}, true},
+		{"invalidRename", Rule{Match: RuleMatch{Extensions: []string{"mp4"}}, Rename: "{path}/{file}"}, true},
+		{"invalidDateField", Rule{Match: RuleMatch{Extensions: []string{"mp4"}}, DateFields: []DateField{{Source: "unknown"}}}, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			_, err := tc.rule.compile()
+			assert.Equal(t, tc.expError, err != nil)
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestRuleMatches"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/VID_1234.MP4", "0123456789")
+	fm := FileMetadata{File: dir + "/VID_1234.MP4", Fields: map[string]interface{}{"MIMEType": "video/mp4", "Model": "SM-G930F"}}
+	var tcs = []struct {
+		tcID     string
+		match    RuleMatch
+		fm       FileMetadata
+		expMatch bool
+	}{
+		{"extension", RuleMatch{Extensions: []string{"mov", "mp4"}}, fm, true},
+		{"otherExtension", RuleMatch{Extensions: []string{"jpg"}}, fm, false},
+		{"mimeType", RuleMatch{MimeTypes: []string{"video/mp4"}}, fm, true},
+		{"mimeTypeWildcard", RuleMatch{MimeTypes: []string{"image/*", "video/*"}}, fm, true},
+		{"otherMimeType", RuleMatch{MimeTypes: []string{"image/*"}}, fm, false},
+		{"mimeTypeFromExtension", RuleMatch{MimeTypes: []string{"video/*"}}, FileMetadata{File: fm.File}, true},
+		{"tag", RuleMatch{Tags: map[string]string{"Model": "SM-G930F"}}, fm, true},
+		{"otherTag", RuleMatch{Tags: map[string]string{"Model": "EOS 5D"}}, fm, false},
+		{"missingTag", RuleMatch{Tags: map[string]string{"Make": "samsung"}}, fm, false},
+		{"minSize", RuleMatch{MinSize: 10}, fm, true},
+		{"tooSmall", RuleMatch{MinSize: 11}, fm, false},
+		{"maxSize", RuleMatch{MaxSize: 10}, fm, true},
+		{"tooBig", RuleMatch{MaxSize: 9}, fm, false},
+		{"missingFile", RuleMatch{MinSize: 1}, FileMetadata{File: dir + "/missing.mp4"}, false},
+		{"name", RuleMatch{Paths: []string{"VID_*"}}, fm, true},
+		{"otherName", RuleMatch{Paths: []string{"IMG_*"}}, fm, false},
+		{"allCriteria", RuleMatch{Extensions: []string{"mp4"}, MimeTypes: []string{"video/*"}, Tags: map[string]string{"Model": "SM-G930F"}, MinSize: 1}, fm, true},
+		{"oneCriterionFailing", RuleMatch{Extensions: []string{"mp4"}, Tags: map[string]string{"Model": "EOS 5D"}}, fm, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			assert.Equal(t, tc.expMatch, rule{Rule: Rule{Match: tc.match}}.matches(tc.fm, ""))
+		})
+	}
+}
+
+func TestRuleMatchesPath(t *testing.T) {
+	r := rule{Rule: Rule{Match: RuleMatch{Paths: []string{"*/Screenshots/*", "Screenshot_*"}}}}
+	fm := FileMetadata{File: "/in/phone/Screenshots/a.png"}
+	assert.True(t, r.matches(fm, "phone/Screenshots/a.png"))
+	assert.False(t, r.matches(fm, "phone/Screenshots/old/a.png"))
+	assert.True(t, r.matches(FileMetadata{File: "/in/b/Screenshot_1.png"}, "b/Screenshot_1.png"))
+	assert.True(t, r.matches(FileMetadata{File: "/in/Screenshot_1.png"}, "."))
+	assert.False(t, r.matches(FileMetadata{File: "/in/b/IMG_1.png"}, "b/IMG_1.png"))
+}
+
+func TestSettings(t *testing.T) {
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptDestination("{date:2006}/{file}"),
+		OptOperation(OperationCopy),
+		OptRules([]Rule{
+			{Name: "videos", Match: RuleMatch{Extensions: []string{"mp4"}}, Destination: "videos/{file}", Operation: OperationMove},
+			{Name: "raw", Match: RuleMatch{Extensions: []string{"cr2", "mp4"}}, DateFields: []DateField{{Source: SourceFileName}}, OutputDateFormat: "2006"},
+		}),
+	)
+	assert.Nil(t, err)
+
+	r := c.matchingRule(FileMetadata{File: "/in/a.mp4"}, "a.mp4")
+	assert.Equal(t, "videos", r.Name)
+	s := c.settings(r)
+	assert.Equal(t, OperationMove, s.operation)
+	assert.Equal(t, "videos/{file}", s.destination.source)
+	assert.Equal(t, c.dateFields, s.dateFields)
+
+	r = c.matchingRule(FileMetadata{File: "/in/a.CR2"}, "a.CR2")
+	assert.Equal(t, "raw", r.Name)
+	s = c.settings(r)
+	assert.Equal(t, OperationCopy, s.operation)
+	assert.Nil(t, s.destination)
+	assert.Equal(t, "2006", s.outputDateFormat)
+	assert.Equal(t, SourceFileName, s.dateFields[0].Source)
+
+	r = c.matchingRule(FileMetadata{File: "/in/a.jpg"}, "a.jpg")
+	assert.Nil(t, r)
+	s = c.settings(r)
+	assert.Equal(t, OperationCopy, s.operation)
+	assert.Equal(t, "{date:2006}/{file}", s.destination.source)
+	assert.Equal(t, "2006_01", s.outputDateFormat)
+}
+
+func TestPlanRules(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestPlanRules"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/20190404_131804.jpg", "a")
+	writeFile(t, dir+"/in/VID_20180102_101010.mp4", "b")
+	writeFile(t, dir+"/in/Screenshots/Screenshot_20170102-101010.png", "c")
+
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptRules([]Rule{
+			{Name: "screenshots", Match: RuleMatch{Paths: []string{"Screenshots/*"}}, DateFields: []DateField{{Source: SourceFileName}}, Destination: "screenshots/{date:2006}/{file}"},
+			{Name: "videos", Match: RuleMatch{MimeTypes: []string{"video/*"}}, DateFields: []DateField{{Source: SourceFileName}}, OutputDateFormat: "2006"},
+		}),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan(dir+"/in", "/out")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlannedMove{
+		{From: dir + "/in/20190404_131804.jpg", To: "/out/2019_04/20190404_131804.jpg"},
+		{From: dir + "/in/Screenshots/Screenshot_20170102-101010.png", To: "/out/screenshots/2017/Screenshot_20170102-101010.png"},
+		{From: dir + "/in/VID_20180102_101010.mp4", To: "/out/2018/VID_20180102_101010.mp4"},
+	}, p.Moves)
+	assert.Empty(t, p.Unclassified)
+}
+
+func TestClassifyRulesOperation(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestClassifyRulesOperation"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/20190404_131804.jpg", "a")
+	writeFile(t, dir+"/in/20190404_131805.mp4", "b")
+
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Source: SourceFileName}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptRules([]Rule{{Match: RuleMatch{Extensions: []string{"mp4"}}, Operation: OperationCopy}}),
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Classify(dir+"/in", dir+"/out"))
+	checkExist(t, dir+"/in/20190404_131804.jpg", false)
+	checkExist(t, dir+"/out/2019_04/20190404_131804.jpg", true)
+	checkExist(t, dir+"/in/20190404_131805.mp4", true)
+	checkExist(t, dir+"/out/2019_04/20190404_131805.mp4", true)
+}
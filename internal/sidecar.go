@@ -0,0 +1,206 @@
+package classifier
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultXMPFields are the XMP properties holding the capture date, by decreasing priority, used when
+// no field is specified for a SourceXMP date field
+var DefaultXMPFields = []string{"exif:DateTimeOriginal", "xmp:CreateDate", "photoshop:DateCreated"}
+
+// DefaultXMPPatterns are the date layouts used when no pattern is specified for a SourceXMP date field
+// (XMP dates are ISO 8601 dates with optional time, fraction of second and offset)
+var DefaultXMPPatterns = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// DefaultTakeoutField is the Google Takeout JSON key holding the capture date
+const DefaultTakeoutField = "photoTakenTime"
+
+// xmpNamespaces are the namespaces of the usual XMP prefixes
+var xmpNamespaces = map[string]string{
+	"exif":      "http://ns.adobe.com/exif/1.0/",
+	"exifEX":    "http://cipa.jp/exif/1.0/",
+	"xmp":       "http://ns.adobe.com/xap/1.0/",
+	"photoshop": "http://ns.adobe.com/photoshop/1.0/",
+	"tiff":      "http://ns.adobe.com/tiff/1.0/",
+	"dc":        "http://purl.org/dc/elements/1.1/",
+}
+
+func (df *DateField) initXMP() {
+	if len(df.Patterns) == 0 {
+		df.Patterns = DefaultXMPPatterns
+	}
+}
+
+// sidecar returns the first existing file among the candidates
+func sidecar(candidates ...string) (string, bool) {
+	for _, c := range candidates {
+		if fi, err := os.Stat(c); err == nil && !fi.IsDir() {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// xmpSidecar locates the XMP sidecar of a file : IMG_1234.xmp (Lightroom, darktable) or IMG_1234.CR2.xmp
+func xmpSidecar(file string) (string, bool) {
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	return sidecar(base+".xmp", base+".XMP", file+".xmp", file+".XMP")
+}
+
+// takeoutSidecar locates the Google Takeout JSON sidecar of a file (IMG_1234.jpg.json)
+func takeoutSidecar(file string) (string, bool) {
+	return sidecar(file+".json", file+".supplemental-metadata.json")
+}
+
+// xmpDate reads the date from the XMP sidecar of the file. The first property found among the
+// field (or DefaultXMPFields) is used.
+func (df DateField) xmpDate(file string) (time.Time, bool, error) {
+	sc, found := xmpSidecar(file)
+	if !found {
+		return time.Time{}, false, nil
+	}
+	fields := DefaultXMPFields
+	if df.Field != "" {
+		fields = []string{df.Field}
+	}
+	props, err := readXMP(sc)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("error while reading %v: %v", sc, err)
+	}
+	for _, f := range fields {
+		if val, found := props.get(f); found {
+			if isZeroDate(val) {
+				return time.Time{}, true, zeroDateError{value: val}
+			}
+			t, err := df.parse(val)
+			return t, true, err
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// xmpProperties are the simple properties of an XMP packet, by namespace and local name
+type xmpProperties map[xml.Name]string
+
+// get returns the value of a property named prefix:name. Unknown prefixes only match on the name.
+func (props xmpProperties) get(field string) (string, bool) {
+	prefix, local := "", field
+	if i := strings.Index(field, ":"); i >= 0 {
+		prefix, local = field[:i], field[i+1:]
+	}
+	if ns, found := xmpNamespaces[prefix]; found {
+		val, found := props[xml.Name{Space: ns, Local: local}]
+		return val, found
+	}
+	for name, val := range props {
+		if name.Local == local {
+			return val, true
+		}
+	}
+	return "", false
+}
+
+// readXMP reads the properties of an XMP file, written either as attributes of the rdf:Description
+// elements or as elements holding text
+func readXMP(file string) (xmpProperties, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	props := xmpProperties{}
+	dec := xml.NewDecoder(f)
+	var current *xml.Name
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return props, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for _, a := range t.Attr {
+				if _, found := props[a.Name]; !found {
+					props[a.Name] = strings.TrimSpace(a.Value)
+				}
+			}
+			name := t.Name
+			current = &name
+			text.Reset()
+		case xml.CharData:
+			if current != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if current != nil && *current == t.Name {
+				if val := strings.TrimSpace(text.String()); val != "" {
+					if _, found := props[t.Name]; !found {
+						props[t.Name] = val
+					}
+				}
+			}
+			current = nil
+		}
+	}
+}
+
+// takeoutTime is a date of a Google Takeout JSON file
+type takeoutTime struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// takeoutDate reads the date from the Google Takeout JSON sidecar of the file, the field (or
+// DefaultTakeoutField) holding an epoch timestamp
+func (df DateField) takeoutDate(file string) (time.Time, bool, error) {
+	sc, found := takeoutSidecar(file)
+	if !found {
+		return time.Time{}, false, nil
+	}
+	field := df.Field
+	if field == "" {
+		field = DefaultTakeoutField
+	}
+	b, err := ioutil.ReadFile(sc)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("error while reading %v: %v", sc, err)
+	}
+	content := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &content); err != nil {
+		return time.Time{}, true, fmt.Errorf("error while parsing %v: %v", sc, err)
+	}
+	raw, found := content[field]
+	if !found {
+		return time.Time{}, false, nil
+	}
+	var tt takeoutTime
+	if err := json.Unmarshal(raw, &tt); err != nil {
+		return time.Time{}, true, fmt.Errorf("error while parsing %v of %v: %v", field, sc, err)
+	}
+	ts, err := strconv.ParseInt(tt.Timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("invalid timestamp %v in %v: %v", tt.Timestamp, sc, err)
+	}
+	if ts == 0 {
+		return time.Time{}, true, zeroDateError{value: tt.Timestamp}
+	}
+	return time.Unix(ts, 0).UTC(), true, nil
+}
@@ -0,0 +1,136 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testXMPAttributes = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+   xmp:CreateDate="2018-01-02T03:04:05"
+   exif:DateTimeOriginal="2019-04-04T13:18:04.12+02:00"/>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+const testXMPElements = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/">
+   <photoshop:DateCreated>2017-05-06</photoshop:DateCreated>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+const testTakeout = `{
+  "title": "IMG_1234.jpg",
+  "creationTime": { "timestamp": "1554383884", "formatted": "4 avr. 2019, 13:18:04 UTC" },
+  "photoTakenTime": { "timestamp": "1554370684", "formatted": "4 avr. 2019, 09:38:04 UTC" }
+}`
+
+func TestXMPDate(t *testing.T) {
+	dir := "../testdata/tmp/TestXMPDate/"
+	writeFile(t, dir+"attributes.xmp", testXMPAttributes)
+	writeFile(t, dir+"elements.CR2.xmp", testXMPElements)
+	writeFile(t, dir+"upper.XMP", testXMPAttributes)
+	writeFile(t, dir+"zero.xmp", `<x:xmpmeta xmlns:x="adobe:ns:meta/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:CreateDate="0000-00-00T00:00:00"/>`)
+	writeFile(t, dir+"invalid.xmp", `<x:xmpmeta xmlns:x="adobe:ns:meta/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:CreateDate="yesterday"/>`)
+	writeFile(t, dir+"unparsable.xmp", `<x:xmpmeta`)
+
+	var tcs = []struct {
+		tcID     string
+		field    string
+		file     string
+		expTime  time.Time
+		expFound bool
+		expError bool
+	}{
+		{"defaultFields", "", dir + "attributes.jpg", time.Date(2019, 4, 4, 11, 18, 4, 120000000, time.UTC), true, false},
+		{"field", "xmp:CreateDate", dir + "attributes.jpg", time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC), true, false},
+		{"unknownPrefix", "foo:CreateDate", dir + "attributes.jpg", time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC), true, false},
+		{"missingField", "xmp:ModifyDate", dir + "attributes.jpg", time.Time{}, false, false},
+		{"elements", "", dir + "elements.CR2", time.Date(2017, 5, 6, 0, 0, 0, 0, time.UTC), true, false},
+		{"upperCase", "", dir + "upper.jpg", time.Date(2019, 4, 4, 11, 18, 4, 120000000, time.UTC), true, false},
+		{"noSidecar", "", dir + "none.jpg", time.Time{}, false, false},
+		{"zero", "", dir + "zero.jpg", time.Time{}, true, true},
+		{"invalid", "", dir + "invalid.jpg", time.Time{}, true, true},
+		{"unparsable", "", dir + "unparsable.jpg", time.Time{}, true, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			df := DateField{Source: SourceXMP, Field: tc.field}
+			assert.Nil(t, df.init())
+			got, found, err := df.xmpDate(tc.file)
+			assert.Equal(t, tc.expFound, found)
+			assert.Equal(t, tc.expError, err != nil)
+			assert.True(t, tc.expTime.Equal(got), "%v != %v", tc.expTime, got)
+		})
+	}
+}
+
+func TestTakeoutDate(t *testing.T) {
+	dir := "../testdata/tmp/TestTakeoutDate/"
+	writeFile(t, dir+"IMG_1234.jpg.json", testTakeout)
+	writeFile(t, dir+"IMG_1235.jpg.supplemental-metadata.json", testTakeout)
+	writeFile(t, dir+"unparsable.jpg.json", `{`)
+	writeFile(t, dir+"invalid.jpg.json", `{ "photoTakenTime": { "timestamp": "yesterday" } }`)
+	writeFile(t, dir+"zero.jpg.json", `{ "photoTakenTime": { "timestamp": "0" } }`)
+
+	var tcs = []struct {
+		tcID     string
+		field    string
+		file     string
+		expTime  time.Time
+		expFound bool
+		expError bool
+	}{
+		{"defaultField", "", dir + "IMG_1234.jpg", time.Date(2019, 4, 4, 9, 38, 4, 0, time.UTC), true, false},
+		{"field", "creationTime", dir + "IMG_1234.jpg", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), true, false},
+		{"missingField", "modificationTime", dir + "IMG_1234.jpg", time.Time{}, false, false},
+		{"supplemental", "", dir + "IMG_1235.jpg", time.Date(2019, 4, 4, 9, 38, 4, 0, time.UTC), true, false},
+		{"noSidecar", "", dir + "none.jpg", time.Time{}, false, false},
+		{"unparsable", "", dir + "unparsable.jpg", time.Time{}, true, true},
+		{"invalid", "", dir + "invalid.jpg", time.Time{}, true, true},
+		{"zero", "", dir + "zero.jpg", time.Time{}, true, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			df := DateField{Source: SourceTakeout, Field: tc.field}
+			assert.Nil(t, df.init())
+			got, found, err := df.takeoutDate(tc.file)
+			assert.Equal(t, tc.expFound, found)
+			assert.Equal(t, tc.expError, err != nil)
+			assert.Equal(t, tc.expTime, got)
+		})
+	}
+}
+
+func TestGuessDateSidecarPriority(t *testing.T) {
+	dir := "../testdata/tmp/TestGuessDateSidecarPriority/"
+	writeFile(t, dir+"IMG_1234.jpg.json", testTakeout)
+	writeFile(t, dir+"IMG_1234.xmp", testXMPElements)
+
+	c, err := NewClassifier(OptDateFields([]DateField{
+		{Field: "CreateDate", Patterns: []string{"exif"}},
+		{Source: SourceTakeout},
+		{Source: SourceXMP},
+	}))
+	assert.Nil(t, err)
+
+	got, _, err := c.guessDate(FileMetadata{File: dir + "IMG_1234.jpg", Fields: map[string]interface{}{}})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2019, 4, 4, 9, 38, 4, 0, time.UTC), got)
+
+	got, _, err = c.guessDate(FileMetadata{File: dir + "IMG_1234.jpg", Fields: map[string]interface{}{"CreateDate": "2016:01:01 00:00:00"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2016, got.Year())
+
+	got, _, err = c.guessDate(FileMetadata{File: dir + "IMG_1234.CR2", Fields: map[string]interface{}{}})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2017, 5, 6, 0, 0, 0, 0, time.UTC), got)
+}
@@ -0,0 +1,329 @@
+package classifier
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTemplateValue replaces the empty values in the templates
+const defaultTemplateValue = "unknown"
+
+// templateContext holds what the templates can refer to
+type templateContext struct {
+	date   time.Time
+	fields map[string]interface{}
+	// from is the file path
+	from string
+	// rel is the folder of the file, relative to the input folder
+	rel string
+	// counter is the value of the {counter} token
+	counter int
+	// locale gives the month, weekday and season names, English if nil
+	locale *locale
+	// fiscalYearStart is the first month of the fiscal years, January if zero
+	fiscalYearStart time.Month
+}
+
+// names returns the locale of the month and weekday names
+func (ctx templateContext) names() *locale {
+	if ctx.locale == nil {
+		return locales[defaultLocale]
+	}
+	return ctx.locale
+}
+
+// tokenFunc computes the value of a token, arg being the text after the colon ({date:2006} -> 2006)
+type tokenFunc func(ctx templateContext, arg string) string
+
+// tokens are the functions evaluating the template tokens by name
+var tokens = map[string]tokenFunc{
+	"date": func(ctx templateContext, arg string) string {
+		return ctx.date.Format(arg)
+	},
+	"meta": func(ctx templateContext, arg string) string {
+		v, found := ctx.fields[arg]
+		if !found {
+			return ""
+		}
+		return sanitize(fmt.Sprintf("%v", v))
+	},
+	"name": func(ctx templateContext, arg string) string {
+		return changeCase(stem(filepath.Base(ctx.from)), arg)
+	},
+	"ext": func(ctx templateContext, arg string) string {
+		return changeCase(filepath.Ext(ctx.from), arg)
+	},
+	"file": func(ctx templateContext, arg string) string {
+		return changeCase(filepath.Base(ctx.from), arg)
+	},
+	"path": func(ctx templateContext, arg string) string {
+		return sanitizePath(ctx.rel)
+	},
+	"subsec": func(ctx templateContext, arg string) string {
+		return fmt.Sprintf("%09d", ctx.date.Nanosecond())[:width(arg, 3, 9)]
+	},
+	"counter": func(ctx templateContext, arg string) string {
+		return fmt.Sprintf("%0*d", width(arg, 1, 9), ctx.counter)
+	},
+	"month": func(ctx templateContext, arg string) string {
+		return changeCase(ctx.names().month(ctx.date.Month()), arg)
+	},
+	"monthabbr": func(ctx templateContext, arg string) string {
+		return changeCase(ctx.names().shortMonth(ctx.date.Month()), arg)
+	},
+	"weekday": func(ctx templateContext, arg string) string {
+		return changeCase(ctx.names().weekday(ctx.date.Weekday()), arg)
+	},
+	"weekdayabbr": func(ctx templateContext, arg string) string {
+		return changeCase(ctx.names().shortWeekday(ctx.date.Weekday()), arg)
+	},
+	"isoyear": func(ctx templateContext, arg string) string {
+		year, _ := ctx.date.ISOWeek()
+		return strconv.Itoa(year)
+	},
+	"isoweek": func(ctx templateContext, arg string) string {
+		_, week := ctx.date.ISOWeek()
+		return fmt.Sprintf("%02d", week)
+	},
+	"quarter": func(ctx templateContext, arg string) string {
+		return strconv.Itoa(quarter(ctx.date))
+	},
+	"half": func(ctx templateContext, arg string) string {
+		return strconv.Itoa(half(ctx.date))
+	},
+	"yearday": func(ctx templateContext, arg string) string {
+		return fmt.Sprintf("%03d", ctx.date.YearDay())
+	},
+	"fiscalyear": func(ctx templateContext, arg string) string {
+		return strconv.Itoa(fiscalYear(ctx.date, ctx.fiscalYearStart))
+	},
+	"fiscalquarter": func(ctx templateContext, arg string) string {
+		return strconv.Itoa(fiscalQuarter(ctx.date, ctx.fiscalYearStart))
+	},
+	"season": func(ctx templateContext, arg string) string {
+		s, _ := season(ctx.date)
+		return changeCase(ctx.names().season(s), arg)
+	},
+	"seasonyear": func(ctx templateContext, arg string) string {
+		_, year := season(ctx.date)
+		return strconv.Itoa(year)
+	},
+}
+
+// width parses the width argument of a token ({counter:3}), def being used if not specified or invalid
+func width(arg string, def int, max int) int {
+	w, err := strconv.Atoi(arg)
+	if err != nil || w < 1 {
+		return def
+	}
+	if w > max {
+		return max
+	}
+	return w
+}
+
+// tokensWithArg are the tokens requiring an argument
+var tokensWithArg = map[string]bool{"date": true, "meta": true}
+
+// tokensAllowedEmpty are the tokens whose value can legitimately be empty (file without extension,
+// file at the root of the source folder), which is then not replaced by "unknown"
+var tokensAllowedEmpty = map[string]bool{"ext": true, "path": true}
+
+// templatePart is either a literal text or a token ({kind:arg|default})
+type templatePart struct {
+	literal string
+	kind    string
+	arg     string
+	def     string
+}
+
+// pathTemplate is a parsed template such as {date:2006}/{date:01}/{meta:Model|unknown}/{name}{ext}.
+// Braces are escaped by doubling them ({{ and }}).
+type pathTemplate struct {
+	source string
+	parts  []templatePart
+}
+
+func parseTemplate(s string) (*pathTemplate, error) {
+	t := pathTemplate{source: s}
+	var literal strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{"), strings.HasPrefix(s[i:], "}}"):
+			literal.WriteByte(s[i])
+			i++
+		case s[i] == '}':
+			return nil, fmt.Errorf("unexpected } at position %v in template %v", i, s)
+		case s[i] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 || strings.IndexByte(s[i+1:i+end], '{') >= 0 {
+				return nil, fmt.Errorf("unclosed { at position %v in template %v", i, s)
+			}
+			p, err := parseToken(s[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("error in template %v: %v", s, err)
+			}
+			if literal.Len() > 0 {
+				t.parts = append(t.parts, templatePart{literal: literal.String()})
+				literal.Reset()
+			}
+			t.parts = append(t.parts, p)
+			i += end
+		default:
+			literal.WriteByte(s[i])
+		}
+	}
+	if literal.Len() > 0 {
+		t.parts = append(t.parts, templatePart{literal: literal.String()})
+	}
+	if len(t.parts) == 0 {
+		return nil, fmt.Errorf("empty template")
+	}
+	if last := t.parts[len(t.parts)-1]; last.kind == "" && strings.HasSuffix(last.literal, "/") {
+		return nil, fmt.Errorf("template %v doesn't define any file name", s)
+	}
+	return &t, nil
+}
+
+func parseToken(s string) (templatePart, error) {
+	p := templatePart{}
+	if i := strings.IndexByte(s, '|'); i >= 0 {
+		s, p.def = s[:i], sanitize(s[i+1:])
+	}
+	p.kind = s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		p.kind, p.arg = s[:i], s[i+1:]
+	}
+	if _, found := tokens[p.kind]; !found {
+		return p, fmt.Errorf("unknown token {%v}", s)
+	}
+	if tokensWithArg[p.kind] && p.arg == "" {
+		return p, fmt.Errorf("token {%v} requires an argument", s)
+	}
+	return p, nil
+}
+
+// hasCounter checks if the template holds a {counter} token
+func (t *pathTemplate) hasCounter() bool {
+	if t == nil {
+		return false
+	}
+	for _, p := range t.parts {
+		if p.kind == "counter" {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate computes the path designated by the template. Empty token values are replaced by their
+// default value, or by "unknown" if none is specified (except for the tokens allowed to be empty).
+func (t pathTemplate) evaluate(ctx templateContext) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.kind == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		v := tokens[p.kind](ctx, p.arg)
+		if v == "" {
+			v = p.def
+		}
+		if v == "" && !tokensAllowedEmpty[p.kind] {
+			v = defaultTemplateValue
+		}
+		b.WriteString(v)
+	}
+	return filepath.Clean(b.String())
+}
+
+func changeCase(s string, arg string) string {
+	switch arg {
+	case "lower":
+		return strings.ToLower(s)
+	case "upper":
+		return strings.ToUpper(s)
+	}
+	return s
+}
+
+// sanitize replaces the characters that are not allowed in file names on common filesystems
+// (path separators, control and Windows reserved characters) and trims spaces and dots
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	return strings.Trim(s, " .")
+}
+
+// sanitizePath sanitizes every folder of a relative path, the current folder giving an empty path
+func sanitizePath(rel string) string {
+	parts := []string{}
+	for _, p := range strings.Split(filepath.ToSlash(rel), "/") {
+		if p == "" || p == "." {
+			continue
+		}
+		if p = sanitize(p); p == "" {
+			p = "_"
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// OptRename specifies the template of the destination file names, such as
+// {date:20060102_150405}_{subsec}_{meta:Model}_{counter:3}{ext:lower}. The {counter} token is replaced
+// by the lowest number giving a name that is not used yet in the destination folder. If empty, the
+// file names computed by the destination template (or the original names) are kept.
+func OptRename(template string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if template == "" {
+			c.rename = nil
+			return nil
+		}
+		t, err := parseRenameTemplate(template)
+		if err != nil {
+			return err
+		}
+		c.rename = t
+		return nil
+	}
+}
+
+// parseRenameTemplate parses a file name template, which can't define folders
+func parseRenameTemplate(template string) (*pathTemplate, error) {
+	t, err := parseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range t.parts {
+		if strings.Contains(p.literal, "/") || p.kind == "path" {
+			return nil, fmt.Errorf("rename template %v can't define folders", template)
+		}
+	}
+	return t, nil
+}
+
+// OptDestination specifies the template of the destination paths, relative to the output folder, such
+// as {date:2006}/{date:01}/{meta:Model}/{name}{ext}. The output date format is ignored when a template
+// is specified.
+func OptDestination(template string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if template == "" {
+			c.destination = nil
+			return nil
+		}
+		t, err := parseTemplate(template)
+		if err != nil {
+			return err
+		}
+		c.destination = t
+		return nil
+	}
+}
@@ -0,0 +1,197 @@
+package classifier
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTemplate(t *testing.T) {
+	var tcs = []struct {
+		tcID     string
+		template string
+		expError bool
+	}{
+		{"nominal", "{date:2006}/{date:01}/{meta:Model}/{name}{ext}", false},
+		{"literal", "photos/{file}", false},
+		{"default", "{meta:Model|no camera}/{file}", false},
+		{"escaped", "{{{date:2006}}}/{file}", false},
+		{"unknownToken", "{size}/{file}", true},
+		{"missingArgument", "{date}/{file}", true},
+		{"unclosed", "{date:2006/{file}", true},
+		{"unopened", "date}/{file}", true},
+		{"noFileName", "{date:2006}/", true},
+		{"empty", "", true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			_, err := parseTemplate(tc.template)
+			assert.Equal(t, tc.expError, err != nil)
+		})
+	}
+}
+
+func TestTemplateEvaluate(t *testing.T) {
+	ctx := templateContext{
+		date:   time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC),
+		fields: map[string]interface{}{"Model": "EOS 5D: Mark/II", "ISO": 200, "Empty": " . "},
+		from:   "/in/a/b/IMG_1234.JPG",
+		rel:    "a/b",
+	}
+	var tcs = []struct {
+		tcID     string
+		template string
+		expPath  string
+	}{
+		{"nominal", "{date:2006}/{date:01}/{meta:Model}/{name}{ext}", "2019/04/EOS 5D_ Mark_II/IMG_1234.JPG"},
+		{"dateLayout", "{date:2006/01-02}/{file}", "2019/04-04/IMG_1234.JPG"},
+		{"numericMeta", "iso{meta:ISO}/{file}", "iso200/IMG_1234.JPG"},
+		{"missingMeta", "{meta:Make}/{file}", "unknown/IMG_1234.JPG"},
+		{"missingMetaDefault", "{meta:Make|other}/{file}", "other/IMG_1234.JPG"},
+		{"emptyMeta", "{meta:Empty|none}/{file}", "none/IMG_1234.JPG"},
+		{"case", "{name:lower}{ext:lower}", "img_1234.jpg"},
+		{"path", "{date:2006}/{path}/{file}", "2019/a/b/IMG_1234.JPG"},
+		{"escaped", "{{x}}/{file}", "{x}/IMG_1234.JPG"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			tmpl, err := parseTemplate(tc.template)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expPath, tmpl.evaluate(ctx))
+		})
+	}
+
+	tmpl, err := parseTemplate("{date:2006}/{path}/{file}")
+	assert.Nil(t, err)
+	ctx.rel = "."
+	assert.Equal(t, "2019/IMG_1234.JPG", tmpl.evaluate(ctx))
+
+	tmpl, err = parseTemplate("{name}{ext}")
+	assert.Nil(t, err)
+	ctx.from = "/in/a/b/README"
+	assert.Equal(t, "README", tmpl.evaluate(ctx))
+}
+
+func TestSanitize(t *testing.T) {
+	assert.Equal(t, "a_b_c_d", sanitize("a/b\\c:d"))
+	assert.Equal(t, "a_b", sanitize("a\tb"))
+	assert.Equal(t, "x", sanitize(" ..x.. "))
+	assert.Equal(t, "a/_/c", sanitizePath("a/../c"))
+	assert.Equal(t, "", sanitizePath("."))
+}
+
+func TestPlanDestination(t *testing.T) {
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptDestination("{date:2006}/{meta:Model}/{path}/{name}{ext:upper}"),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan("../testdata/input", "/out")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlannedMove{
+		{From: "../testdata/input/20190404_131804.jpg", To: "/out/2019/SM-G930F/20190404_131804.JPG"},
+		{From: "../testdata/input/subFolder/20190404_131805.jpg", To: "/out/2019/SM-G930F/subFolder/20190404_131805.JPG"},
+		{From: "../testdata/input/subFolder/20190404_131806.jpg", To: "/out/2019/SM-G930F/subFolder/20190404_131806.JPG"},
+	}, p.Moves)
+
+	_, err = NewClassifier(OptDestination("{unknown}/{file}"))
+	assert.NotNil(t, err)
+}
+
+func TestTemplateCounterAndSubSeconds(t *testing.T) {
+	ctx := templateContext{date: time.Date(2019, 4, 4, 13, 18, 4, 123456789, time.UTC), from: "/in/IMG_1.jpg", counter: 7}
+	var tcs = []struct {
+		tcID     string
+		template string
+		expName  string
+	}{
+		{"subsec", "{date:150405}{subsec}{ext}", "131804123.jpg"},
+		{"subsecWidth", "{date:150405}_{subsec:6}{ext}", "131804_123456.jpg"},
+		{"counter", "{name}_{counter}{ext}", "IMG_1_7.jpg"},
+		{"counterWidth", "{name}_{counter:3}{ext}", "IMG_1_007.jpg"},
+		{"invalidWidth", "{name}_{counter:x}{ext}", "IMG_1_7.jpg"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			tmpl, err := parseTemplate(tc.template)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expName, tmpl.evaluate(ctx))
+		})
+	}
+
+	tmpl, err := parseTemplate("{name}_{counter}{ext}")
+	assert.Nil(t, err)
+	assert.True(t, tmpl.hasCounter())
+	tmpl, err = parseTemplate("{name}{ext}")
+	assert.Nil(t, err)
+	assert.False(t, tmpl.hasCounter())
+	assert.False(t, (*pathTemplate)(nil).hasCounter())
+}
+
+func TestOptRename(t *testing.T) {
+	_, err := NewClassifier(OptRename("{date:2006}/{name}{ext}"))
+	assert.NotNil(t, err)
+	_, err = NewClassifier(OptRename("{path}{ext}"))
+	assert.NotNil(t, err)
+	_, err = NewClassifier(OptRename("{nam}{ext}"))
+	assert.NotNil(t, err)
+	_, err = NewClassifier(OptRename("{date:20060102_150405}{ext}"))
+	assert.Nil(t, err)
+}
+
+func TestPlanRenameWithCounter(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestPlanRenameWithCounter"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/20190404_131804.jpg", "a")
+	writeFile(t, dir+"/in/sub/20190404_131805.jpg", "b")
+	writeFile(t, dir+"/in/sub/20190404_131806.jpg", "c")
+
+	c, err := NewClassifier(
+		OptDateFields([]DateField{{Field: "CreateDate", Patterns: []string{"exif"}}}),
+		OptMetadataExtractor(fakeExtractorFactory),
+		OptRename("{date:20060102_150405}_{meta:Model}_{counter:2}{ext}"),
+	)
+	assert.Nil(t, err)
+	p, err := c.Plan(dir+"/in", "/out")
+	assert.Nil(t, err)
+	assert.Equal(t, []PlannedMove{
+		{From: dir + "/in/20190404_131804.jpg", To: "/out/2019_04/20190404_131803_SM-G930F_01.jpg"},
+		{From: dir + "/in/sub/20190404_131805.jpg", To: "/out/2019_04/20190404_131803_SM-G930F_02.jpg"},
+		{From: dir + "/in/sub/20190404_131806.jpg", To: "/out/2019_04/20190404_131803_SM-G930F_03.jpg"},
+	}, p.Moves)
+}
+
+func TestMoveFilesRenameWithCounter(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestMoveFilesRenameWithCounter"
+	assert.Nil(t, os.RemoveAll(dir))
+	writeFile(t, dir+"/in/a.jpg", "a")
+	writeFile(t, dir+"/in/b.jpg", "b")
+	writeFile(t, dir+"/in/b.xmp", "xmp")
+	writeFile(t, dir+"/out/2019_04/20190404_131804_1.jpg", "other")
+	writeFile(t, dir+"/out/2019_04/20190404_131804_2.jpg", "a")
+	writeFile(t, dir+"/out/2019_04/20190404_131804_3.xmp", "other xmp")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	moveChan := make(chan moveAction, 2)
+	d := time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC)
+	moveChan <- moveAction{from: dir + "/in/a.jpg", to: "2019_04", date: d}
+	moveChan <- moveAction{from: dir + "/in/b.jpg", to: "2019_04", date: d, companions: []string{dir + "/in/b.xmp"}}
+	close(moveChan)
+	var wgGlobal sync.WaitGroup
+	wgGlobal.Add(1)
+
+	c := buildDefaultClassifier(t, 2)
+	assert.Nil(t, OptRename("{date:20060102_150405}_{counter}{ext}")(c))
+	c.moveFiles(ctx, cancel, dir+"/in", dir+"/out", moveChan, &wgGlobal)
+
+	checkExist(t, dir+"/in/a.jpg", true)
+	checkExist(t, dir+"/in/b.jpg", false)
+	checkExist(t, dir+"/out/2019_04/20190404_131804_3.jpg", false)
+	checkExist(t, dir+"/out/2019_04/20190404_131804_4.jpg", true)
+	checkExist(t, dir+"/out/2019_04/20190404_131804_4.xmp", true)
+}
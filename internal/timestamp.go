@@ -0,0 +1,45 @@
+package classifier
+
+import (
+	"fmt"
+	"time"
+)
+
+// Filesystem timestamp kinds that can be used by the SourceFileSystem date source
+const (
+	// TimestampModification is the last modification time (default)
+	TimestampModification = "mtime"
+	// TimestampChange is the last status change time
+	TimestampChange = "ctime"
+	// TimestampAccess is the last access time
+	TimestampAccess = "atime"
+	// TimestampBirth is the creation time, only available on some filesystems (statx on linux)
+	TimestampBirth = "birth"
+)
+
+var timestamps = map[string]bool{
+	TimestampModification: true,
+	TimestampChange:       true,
+	TimestampAccess:       true,
+	TimestampBirth:        true,
+}
+
+func (df *DateField) checkTimestamp() error {
+	if df.Timestamp == "" {
+		df.Timestamp = TimestampModification
+	}
+	if !timestamps[df.Timestamp] {
+		return fmt.Errorf("unknown timestamp kind %v", df.Timestamp)
+	}
+	return nil
+}
+
+// fileSystemDate returns the filesystem timestamp of the file. It returns false if the timestamp is
+// not available on the filesystem.
+func (df DateField) fileSystemDate(file string) (time.Time, bool, error) {
+	t, found, err := fileTimestamp(file, df.Timestamp)
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("error while reading %v of %v: %v", df.Timestamp, file, err)
+	}
+	return t, found, nil
+}
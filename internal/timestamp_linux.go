@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package classifier
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var statxMasks = map[string]uint32{
+	TimestampModification: unix.STATX_MTIME,
+	TimestampChange:       unix.STATX_CTIME,
+	TimestampAccess:       unix.STATX_ATIME,
+	TimestampBirth:        unix.STATX_BTIME,
+}
+
+// fileTimestamp reads a timestamp of the file with statx, falling back to stat on kernels without
+// statx (birth time is then unavailable)
+func fileTimestamp(file string, kind string) (time.Time, bool, error) {
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, file, unix.AT_SYMLINK_NOFOLLOW, int(statxMasks[kind]), &stx)
+	if err == unix.ENOSYS {
+		return statTimestamp(file, kind)
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if stx.Mask&statxMasks[kind] == 0 {
+		return time.Time{}, false, nil
+	}
+	var ts unix.StatxTimestamp
+	switch kind {
+	case TimestampChange:
+		ts = stx.Ctime
+	case TimestampAccess:
+		ts = stx.Atime
+	case TimestampBirth:
+		ts = stx.Btime
+	default:
+		ts = stx.Mtime
+	}
+	return time.Unix(ts.Sec, int64(ts.Nsec)), true, nil
+}
+
+func statTimestamp(file string, kind string) (time.Time, bool, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(file, &st); err != nil {
+		return time.Time{}, false, err
+	}
+	switch kind {
+	case TimestampChange:
+		return time.Unix(st.Ctim.Unix()), true, nil
+	case TimestampAccess:
+		return time.Unix(st.Atim.Unix()), true, nil
+	case TimestampBirth:
+		return time.Time{}, false, nil
+	default:
+		return time.Unix(st.Mtim.Unix()), true, nil
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package classifier
+
+import (
+	"os"
+	"time"
+)
+
+// fileTimestamp only supports the modification time outside linux
+func fileTimestamp(file string, kind string) (time.Time, bool, error) {
+	if kind != TimestampModification {
+		return time.Time{}, false, nil
+	}
+	fi, err := os.Lstat(file)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return fi.ModTime(), true, nil
+}
@@ -0,0 +1,111 @@
+package classifier
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystemDate(t *testing.T) {
+	file := "../testdata/tmp/TestFileSystemDate/file.jpg"
+	writeFile(t, file, "content")
+	mtime := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+	atime := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Nil(t, os.Chtimes(file, atime, mtime))
+
+	var tcs = []struct {
+		tcID      string
+		timestamp string
+		expTime   time.Time
+	}{
+		{"default", "", mtime},
+		{"mtime", TimestampModification, mtime},
+		{"atime", TimestampAccess, atime},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			df := DateField{Source: SourceFileSystem, Timestamp: tc.timestamp}
+			assert.Nil(t, df.init())
+			got, found, err := df.fileSystemDate(file)
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.True(t, tc.expTime.Equal(got))
+		})
+	}
+
+	for _, ts := range []string{TimestampChange, TimestampBirth} {
+		t.Run(ts, func(t *testing.T) {
+			df := DateField{Source: SourceFileSystem, Timestamp: ts}
+			assert.Nil(t, df.init())
+			got, found, err := df.fileSystemDate(file)
+			assert.Nil(t, err)
+			if found {
+				assert.WithinDuration(t, time.Now(), got, time.Hour)
+			}
+		})
+	}
+
+	df := DateField{Source: SourceFileSystem}
+	assert.Nil(t, df.init())
+	_, found, err := df.fileSystemDate("../testdata/tmp/TestFileSystemDate/nonExisting.jpg")
+	assert.True(t, found)
+	assert.NotNil(t, err)
+}
+
+func TestFileSystemDateFieldInit(t *testing.T) {
+	df := DateField{Source: SourceFileSystem, Timestamp: "unknown"}
+	assert.NotNil(t, df.init())
+}
+
+func TestGuessDateFileSystemFallback(t *testing.T) {
+	file := "../testdata/tmp/TestGuessDateFileSystemFallback/file.jpg"
+	writeFile(t, file, "content")
+	mtime := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+	assert.Nil(t, os.Chtimes(file, mtime, mtime))
+
+	c, err := NewClassifier(OptDateFields([]DateField{
+		{Field: "CreateDate", Patterns: []string{"exif"}},
+		{Source: SourceFileSystem},
+	}))
+	assert.Nil(t, err)
+
+	got, df, err := c.guessDate(FileMetadata{File: file, Fields: map[string]interface{}{"CreateDate": "2019:04:04 13:18:04"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2019, got.Year())
+	assert.False(t, df.lowConfidence())
+
+	got, df, err = c.guessDate(FileMetadata{File: file, Fields: map[string]interface{}{}})
+	assert.Nil(t, err)
+	assert.True(t, mtime.Equal(got))
+	assert.True(t, df.lowConfidence())
+}
+
+func TestPlanLowConfidence(t *testing.T) {
+	dir := "../testdata/tmp/batch/TestPlanLowConfidence"
+	assert.Nil(t, os.RemoveAll(dir))
+	assert.Nil(t, os.MkdirAll(dir+"/in/subFolder", 0777))
+	assert.Nil(t, copy("../testdata/input/20190404_131804.jpg", dir+"/in/20190404_131804.jpg"))
+	assert.Nil(t, copy("../testdata/input/subFolder/noDate.txt", dir+"/in/subFolder/noDate.txt"))
+	mtime := time.Date(2018, 3, 4, 5, 6, 7, 0, time.Local)
+	assert.Nil(t, os.Chtimes(dir+"/in/subFolder/noDate.txt", mtime, mtime))
+
+	c, err := NewClassifier(
+		OptBatchSize(2),
+		OptDateFields([]DateField{
+			{Field: "CreateDate", Patterns: []string{"2006:01:02 15:04:05"}},
+			{Source: SourceFileSystem},
+		}),
+		OptMetadataExtractor(fakeExtractorFactory),
+	)
+	assert.Nil(t, err)
+
+	p, err := c.Plan(dir+"/in", "/out")
+	assert.Nil(t, err)
+	assert.Empty(t, p.Unclassified)
+	assert.Equal(t, []PlannedMove{
+		{From: dir + "/in/20190404_131804.jpg", To: "/out/2019_04/20190404_131804.jpg"},
+		{From: dir + "/in/subFolder/noDate.txt", To: "/out/" + mtime.Format("2006_01") + "/noDate.txt", LowConfidence: true},
+	}, p.Moves)
+}
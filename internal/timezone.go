@@ -0,0 +1,116 @@
+package classifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timezones of the dates without offset
+const (
+	// TimezoneUTC considers the dates as UTC ones (default, QuickTime dates are UTC)
+	TimezoneUTC = "utc"
+	// TimezoneLocal considers the dates as local ones, in the timezone of the machine running the classification
+	TimezoneLocal = "local"
+	// TimezoneEmbedded only accepts dates holding their offset (or whose offset is provided by OffsetField)
+	TimezoneEmbedded = "embedded"
+)
+
+// probeZone is used to detect the values holding their own offset
+var probeZone = time.FixedZone("probe", 3600)
+
+// loadLocation returns the location designated by utc, local or an IANA timezone name (Europe/Paris)
+func loadLocation(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case TimezoneUTC:
+		return time.UTC, nil
+	case TimezoneLocal:
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %v: %v", name, err)
+	}
+	return loc, nil
+}
+
+func (df *DateField) initTimezone() error {
+	if df.Timezone == "" || df.Timezone == TimezoneEmbedded {
+		return nil
+	}
+	loc, err := loadLocation(df.Timezone)
+	if err != nil {
+		return err
+	}
+	df.location = loc
+	return nil
+}
+
+// zone is the location of the dates without offset
+func (df DateField) zone() *time.Location {
+	if df.location == nil {
+		return time.UTC
+	}
+	return df.location
+}
+
+// localize sets the location of a date parsed as UTC : dates holding their own offset (embedded) are
+// kept as is, the others get the offset value (+02:00) if provided or the timezone of the field
+func (df DateField) localize(t time.Time, embedded bool, offset string) (time.Time, error) {
+	if embedded {
+		return t, nil
+	}
+	if offset != "" {
+		loc, err := parseOffset(offset)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return inLocation(t, loc), nil
+	}
+	if df.Timezone == TimezoneEmbedded {
+		return time.Time{}, fmt.Errorf("no offset in %v", t.Format("2006-01-02 15:04:05"))
+	}
+	return inLocation(t, df.zone()), nil
+}
+
+// inLocation returns the date with the same wall clock in another location
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// parseOffset parses an offset such as +02:00, -0500 or Z (EXIF OffsetTime* tags)
+func parseOffset(s string) (*time.Location, error) {
+	s = strings.TrimSpace(s)
+	for _, l := range []string{"Z07:00", "Z0700", "Z07"} {
+		if t, err := time.Parse(l, s); err == nil {
+			_, offset := t.Zone()
+			return time.FixedZone(s, offset), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid offset %v", s)
+}
+
+// OptOutputTimezone specifies the timezone in which the dates are converted before computing the
+// destination (utc, local or IANA timezone name). If empty, the dates are kept in their own timezone.
+func OptOutputTimezone(timezone string) func(*Classifier) error {
+	return func(c *Classifier) error {
+		if timezone == "" {
+			c.outputLocation = nil
+			return nil
+		}
+		loc, err := loadLocation(timezone)
+		if err != nil {
+			return err
+		}
+		c.outputLocation = loc
+		return nil
+	}
+}
+
+// outputDate converts the date in the output timezone
+func (cl *Classifier) outputDate(t time.Time) time.Time {
+	if cl.outputLocation == nil {
+		return t
+	}
+	return t.In(cl.outputLocation)
+}
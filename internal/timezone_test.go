@@ -0,0 +1,118 @@
+package classifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := loadLocation("utc")
+	assert.Nil(t, err)
+	assert.Equal(t, time.UTC, loc)
+	loc, err = loadLocation("Local")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Local, loc)
+	loc, err = loadLocation("Europe/Paris")
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Paris", loc.String())
+	_, err = loadLocation("Europe/Nowhere")
+	assert.NotNil(t, err)
+}
+
+func TestParseOffset(t *testing.T) {
+	var tcs = []struct {
+		tcID      string
+		value     string
+		expOffset int
+		expError  bool
+	}{
+		{"colon", "+02:00", 7200, false},
+		{"noColon", "-0530", -19800, false},
+		{"hours", "+01", 3600, false},
+		{"utc", "Z", 0, false},
+		{"invalid", "02h00", 0, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			loc, err := parseOffset(tc.value)
+			assert.Equal(t, tc.expError, err != nil)
+			if !tc.expError {
+				_, offset := time.Date(2019, 4, 4, 0, 0, 0, 0, loc).Zone()
+				assert.Equal(t, tc.expOffset, offset)
+			}
+		})
+	}
+}
+
+func TestMetadataDateTimezone(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	assert.Nil(t, err)
+	var tcs = []struct {
+		tcID        string
+		timezone    string
+		offsetField string
+		value       string
+		offset      string
+		expTime     time.Time
+		expError    bool
+	}{
+		{"default", "", "", "2019:04:04 13:18:04", "", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), false},
+		{"utc", "utc", "", "2019:04:04 13:18:04", "", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), false},
+		{"local", "local", "", "2019:04:04 13:18:04", "", time.Date(2019, 4, 4, 13, 18, 4, 0, time.Local), false},
+		{"iana", "Europe/Paris", "", "2019:04:04 13:18:04", "", time.Date(2019, 4, 4, 13, 18, 4, 0, paris), false},
+		{"embeddedOffsetWins", "Europe/Paris", "", "2019:04:04 13:18:04-05:00", "", time.Date(2019, 4, 4, 18, 18, 4, 0, time.UTC), false},
+		{"embedded", "embedded", "", "2019:04:04 13:18:04+02:00", "", time.Date(2019, 4, 4, 11, 18, 4, 0, time.UTC), false},
+		{"embeddedMissing", "embedded", "", "2019:04:04 13:18:04", "", time.Time{}, true},
+		{"offsetField", "embedded", "OffsetTimeOriginal", "2019:04:04 13:18:04", "+02:00", time.Date(2019, 4, 4, 11, 18, 4, 0, time.UTC), false},
+		{"offsetFieldMissing", "utc", "OffsetTimeOriginal", "2019:04:04 13:18:04", "", time.Date(2019, 4, 4, 13, 18, 4, 0, time.UTC), false},
+		{"invalidOffset", "utc", "OffsetTimeOriginal", "2019:04:04 13:18:04", "two hours", time.Time{}, true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			df := DateField{Field: "DateTimeOriginal", Patterns: []string{"exif", "exif-tz"}, Timezone: tc.timezone, OffsetField: tc.offsetField}
+			assert.Nil(t, df.init())
+			fields := map[string]interface{}{"DateTimeOriginal": tc.value}
+			if tc.offset != "" {
+				fields["OffsetTimeOriginal"] = tc.offset
+			}
+			got, found, err := df.metadataDate(FileMetadata{Fields: fields})
+			assert.True(t, found)
+			assert.Equal(t, tc.expError, err != nil)
+			assert.True(t, tc.expTime.Equal(got), "%v != %v", tc.expTime, got)
+		})
+	}
+}
+
+func TestDateFieldInitTimezone(t *testing.T) {
+	df := DateField{Field: "f", Patterns: []string{"exif"}, Timezone: "Mars/Olympus"}
+	assert.NotNil(t, df.init())
+}
+
+func TestOptOutputTimezone(t *testing.T) {
+	_, err := NewClassifier(OptOutputTimezone("Mars/Olympus"))
+	assert.NotNil(t, err)
+
+	var tcs = []struct {
+		tcID           string
+		outputTimezone string
+		expTo          string
+	}{
+		{"keepTimezone", "", "2019_04"},
+		{"utc", "utc", "2019_04"},
+		{"paris", "Europe/Paris", "2019_05"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.tcID, func(t *testing.T) {
+			c, err := NewClassifier(
+				OptDateFields([]DateField{{Field: "Media Create Date", Patterns: []string{"exif"}, Timezone: "utc"}}),
+				OptOutputTimezone(tc.outputTimezone),
+			)
+			assert.Nil(t, err)
+			d, _, err := c.guessDate(FileMetadata{File: "video.mov", Fields: map[string]interface{}{"Media Create Date": "2019:04:30 22:30:00"}})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expTo, c.outputDate(d).Format(c.outputDateFormat))
+		})
+	}
+}